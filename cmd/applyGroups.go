@@ -0,0 +1,234 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/goccy/go-yaml"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/donegroup"
+	"github.com/spf13/cobra"
+)
+
+var groupsConcurrency int
+
+var applyGroupsCmd = &cobra.Command{
+	Use:   "apply-groups [USER_POOL_ID_OR_NAME] [GROUPS_FILE]",
+	Short: "apply groups to the user pool",
+	Long:  `apply groups to the user pool.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		idOrName := args[0]
+		p := args[1]
+		if groupsConcurrency < 1 {
+			return fmt.Errorf("invalid concurrency: %d", groupsConcurrency)
+		}
+		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint), userpool.WithRateLimit(rateLimit))
+		if err != nil {
+			return err
+		}
+		groups, err := readGroups(p)
+		if err != nil {
+			return err
+		}
+		var filterRe *regexp.Regexp
+		if filter != "" {
+			filterRe, err = regexp.Compile(filter)
+			if err != nil {
+				return err
+			}
+		}
+
+		if dryRun {
+			slog.Info("dry-run: apply groups started")
+		} else {
+			slog.Info("apply groups started")
+		}
+
+		ctx, cancel := donegroup.WithCancel(ctx)
+		// limit the number of groups applied concurrently
+		sem := make(chan struct{}, groupsConcurrency)
+
+		applied := atomic.Int64{}
+		skipped := atomic.Int64{}
+		pruned := atomic.Int64{}
+		seen := map[string]struct{}{}
+		defer func() {
+			if dryRun {
+				slog.Info("dry-run: apply groups completed", slog.Int64("total", applied.Load()), slog.Int64("skipped", skipped.Load()), slog.Int64("pruned", pruned.Load()))
+				return
+			}
+			slog.Info("apply groups completed", slog.Int64("total", applied.Load()), slog.Int64("skipped", skipped.Load()), slog.Int64("pruned", pruned.Load()))
+		}()
+
+		for _, group := range groups {
+			if filterRe != nil && !filterRe.MatchString(group.Name) {
+				if verbose {
+					slog.Info("skip group", slog.String("name", group.Name))
+				}
+				skipped.Add(1)
+				continue
+			}
+			seen[group.Name] = struct{}{}
+			if verbose {
+				slog.Info("applying group", slog.String("name", group.Name))
+			}
+			if dryRun {
+				applied.Add(1)
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				continue
+			case sem <- struct{}{}:
+			}
+
+			donegroup.Go(ctx, func() error {
+				defer func() { <-sem }()
+				if err := up.ApplyGroup(context.WithoutCancel(ctx), group); err != nil {
+					cancel()
+					return fmt.Errorf("group %s: %w", group.Name, err)
+				}
+				applied.Add(1)
+				return nil
+			})
+		}
+		cancel()
+		if err := donegroup.Wait(ctx); err != nil {
+			return err
+		}
+
+		if !prune {
+			return nil
+		}
+		ctx = context.WithoutCancel(ctx)
+		current, err := up.ListGroups(ctx)
+		if err != nil {
+			return err
+		}
+		var candidates []string
+		for _, g := range current {
+			name := aws.ToString(g.GroupName)
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			if filterRe != nil && !filterRe.MatchString(name) {
+				continue
+			}
+			candidates = append(candidates, name)
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+		if dryRun {
+			for _, name := range candidates {
+				slog.Info("dry-run: delete group", slog.String("name", name))
+				pruned.Add(1)
+			}
+			return nil
+		}
+		if !yes {
+			ok, err := confirmPrune(cmd.InOrStdin(), cmd.OutOrStdout(), "groups", candidates, pruneActionDelete)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				slog.Info("prune canceled")
+				return nil
+			}
+		}
+		for _, name := range candidates {
+			if verbose {
+				slog.Info("delete group", slog.String("name", name))
+			}
+			if err := up.DeleteGroup(ctx, name); err != nil {
+				return fmt.Errorf("delete group %s: %w", name, err)
+			}
+			pruned.Add(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyGroupsCmd)
+	applyGroupsCmd.Flags().StringVarP(&filter, "filter", "f", "", "filter apply groups")
+	applyGroupsCmd.Flags().BoolVar(&dryRun, "dry-run", false, "dry run")
+	applyGroupsCmd.Flags().BoolVar(&prune, "prune", false, "delete groups in the user pool that are not in the groups file")
+	applyGroupsCmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation of pruning")
+	applyGroupsCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	applyGroupsCmd.Flags().StringVarP(&endpoint, "endpoint", "e", "", "set endpoint")
+	applyGroupsCmd.Flags().IntVar(&groupsConcurrency, "concurrency", 10, "number of groups applied concurrently")
+	applyGroupsCmd.Flags().Float64Var(&rateLimit, "rate", 0, "max requests per second of each API quota category. if 0, use the default quotas of Amazon Cognito")
+}
+
+// readGroups reads group definitions from a YAML file (a list of groups) or a JSONL file.
+func readGroups(p string) ([]userpool.Group, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var groups []userpool.Group
+	switch filepath.Ext(p) {
+	case ".yml", ".yaml":
+		if err := yaml.Unmarshal(b, &groups); err != nil {
+			return nil, err
+		}
+	default:
+		// AS JSONL
+		scanner := bufio.NewScanner(strings.NewReader(string(b)))
+		l := 0
+		for scanner.Scan() {
+			l++
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			var g userpool.Group
+			if err := json.Unmarshal([]byte(line), &g); err != nil {
+				return nil, fmt.Errorf("line %d: %w", l, err)
+			}
+			groups = append(groups, g)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for i, g := range groups {
+		if g.Name == "" {
+			return nil, fmt.Errorf("group %d: name is required", i+1)
+		}
+	}
+	return groups, nil
+}
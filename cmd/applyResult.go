@@ -0,0 +1,133 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/k1LoW/coglet/userpool"
+)
+
+const resultOutputJSONL = "jsonl"
+
+const (
+	resultActionCreated   = "created"
+	resultActionUpdated   = "updated"
+	resultActionUnchanged = "unchanged"
+	resultActionSkipped   = "skipped"
+	resultActionFailed    = "failed"
+)
+
+// applyResult is the result record of a line of the users file.
+type applyResult struct {
+	Line       int           `json:"line"`
+	Username   string        `json:"username"`
+	Action     string        `json:"action"`
+	Changes    []applyChange `json:"changes,omitempty"`
+	Password   string        `json:"password,omitempty"`
+	Status     string        `json:"status,omitempty"`
+	DurationMS int64         `json:"duration_ms"`
+	Operation  string        `json:"operation,omitempty"`
+	ErrorCode  string        `json:"error_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type applyChange struct {
+	Name string  `json:"name"`
+	Old  *string `json:"old,omitempty"`
+	New  string  `json:"new"`
+}
+
+// resultWriter writes the result records as JSONL. A nil resultWriter writes nothing.
+type resultWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newResultWriter(w io.Writer) *resultWriter {
+	return &resultWriter{enc: json.NewEncoder(w)}
+}
+
+func validateResultOutput(o string) error {
+	if o != "" && o != resultOutputJSONL {
+		return fmt.Errorf("invalid output format: %s", o)
+	}
+	return nil
+}
+
+func (w *resultWriter) applied(l int, r *userpool.ApplyUserResult, d time.Duration) error {
+	if w == nil {
+		return nil
+	}
+	action := resultActionUnchanged
+	switch r.Action {
+	case userpool.PlanActionCreate:
+		action = resultActionCreated
+	case userpool.PlanActionUpdate:
+		action = resultActionUpdated
+	}
+	res := applyResult{
+		Line:       l,
+		Username:   r.Username,
+		Action:     action,
+		Password:   r.Password,
+		Status:     string(r.Status),
+		DurationMS: d.Milliseconds(),
+	}
+	for _, c := range r.Changes {
+		res.Changes = append(res.Changes, applyChange{Name: c.Name, Old: c.Old, New: c.New})
+	}
+	return w.write(res)
+}
+
+func (w *resultWriter) skipped(l int, username string) error {
+	if w == nil {
+		return nil
+	}
+	return w.write(applyResult{Line: l, Username: username, Action: resultActionSkipped})
+}
+
+func (w *resultWriter) failed(l int, username string, err error, d time.Duration) error {
+	if w == nil {
+		return nil
+	}
+	operation, code := errorDetail(err)
+	return w.write(applyResult{
+		Line:       l,
+		Username:   username,
+		Action:     resultActionFailed,
+		DurationMS: d.Milliseconds(),
+		Operation:  operation,
+		ErrorCode:  code,
+		Error:      err.Error(),
+	})
+}
+
+func (w *resultWriter) write(r applyResult) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(r)
+}
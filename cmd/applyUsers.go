@@ -25,13 +25,16 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"maps"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/k1LoW/coglet/userpool"
 	"github.com/k1LoW/donegroup"
@@ -43,25 +46,51 @@ var (
 	randomPassword        bool
 	permanentPassword     bool
 	sendPasswordResetCode bool
+	reconcileGroups       bool
 	filter                string
 	dryRun                bool
+	plan                  bool
+	prune                 bool
+	pruneAction           string
+	yes                   bool
 	verbose               bool
 	cols                  string
 	skipHeader            int
 	clientMetadata        string
 	endpoint              string
+	concurrency           int
+	rateLimit             float64
+	continueOnError       bool
+	failuresFile          string
+	useCheckpoint         bool
+	resume                bool
+	resultOutput          string
 )
 
 var applyUsersCmd = &cobra.Command{
 	Use:   "apply-users [USER_POOL_ID_OR_NAME] [USERS_FILE]",
 	Short: "apply users to the user pool",
-	Long:  `apply users to the user pool.`,
+	Long:  `apply users to the user pool. In CSV format, empty cells are not applied and the current values are kept.`,
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 		idOrName := args[0]
 		p := args[1]
-		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint))
+		if concurrency < 1 {
+			return fmt.Errorf("invalid concurrency: %d", concurrency)
+		}
+		if err := validateResultOutput(resultOutput); err != nil {
+			return err
+		}
+		var rw *resultWriter
+		promptOut := cmd.OutOrStdout()
+		if resultOutput == resultOutputJSONL {
+			// stdout is for the result records
+			rw = newResultWriter(cmd.OutOrStdout())
+			promptOut = cmd.ErrOrStderr()
+			slog.SetDefault(slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil)))
+		}
+		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint), userpool.WithRateLimit(rateLimit))
 		if err != nil {
 			return err
 		}
@@ -71,6 +100,34 @@ var applyUsersCmd = &cobra.Command{
 		}
 		defer f.Close()
 
+		var (
+			done map[int]string
+			cp   *checkpoint
+		)
+		if useCheckpoint || resume {
+			hash, err := contentHash(f)
+			if err != nil {
+				return err
+			}
+			cpPath := checkpointPath(up.ID(), hash)
+			if resume {
+				done, err = loadCheckpoint(cpPath)
+				if err != nil {
+					return err
+				}
+				slog.Info("resume from checkpoint", slog.String("path", cpPath), slog.Int("applied", len(done)))
+			}
+			if !dryRun && !plan {
+				cp, err = openCheckpoint(cpPath)
+				if err != nil {
+					return err
+				}
+				defer func() {
+					_ = cp.close()
+				}()
+			}
+		}
+
 		scanner := bufio.NewScanner(f)
 		l := 0
 		opts := []userpool.ApplyUserOptionFunc{}
@@ -86,6 +143,12 @@ var applyUsersCmd = &cobra.Command{
 		if sendPasswordResetCode {
 			opts = append(opts, userpool.WithSendPasswordResetCode())
 		}
+		if reconcileGroups {
+			opts = append(opts, userpool.WithReconcileGroups())
+		}
+		if prune && pruneAction != pruneActionDelete && pruneAction != pruneActionDisable {
+			return fmt.Errorf("invalid prune action: %s", pruneAction)
+		}
 		var filterRe *regexp.Regexp
 		if filter != "" {
 			filterRe, err = regexp.Compile(filter)
@@ -94,9 +157,11 @@ var applyUsersCmd = &cobra.Command{
 			}
 		}
 
-		if dryRun {
+		switch {
+		case plan:
+		case dryRun:
 			slog.Info("dry-run: apply users started")
-		} else {
+		default:
 			slog.Info("apply users started")
 		}
 
@@ -106,15 +171,24 @@ var applyUsersCmd = &cobra.Command{
 		}
 
 		ctx, cancel := donegroup.WithCancel(ctx)
+		// limit the number of users applied concurrently
+		sem := make(chan struct{}, concurrency)
 
 		applied := atomic.Int64{}
 		skipped := atomic.Int64{}
+		pruned := atomic.Int64{}
+		report := &failureReport{}
+		ps := &planSummary{}
+		seen := map[string]struct{}{}
 		defer func() {
+			if plan {
+				return
+			}
 			if dryRun {
-				slog.Info("dry-run: apply users completed", slog.Int64("total", applied.Load()), slog.Int64("skipped", skipped.Load()))
+				slog.Info("dry-run: apply users completed", slog.Int64("total", applied.Load()), slog.Int64("skipped", skipped.Load()), slog.Int64("pruned", pruned.Load()), slog.Int("failed", report.len()))
 				return
 			}
-			slog.Info("apply users completed", slog.Int64("total", applied.Load()), slog.Int64("skipped", skipped.Load()))
+			slog.Info("apply users completed", slog.Int64("total", applied.Load()), slog.Int64("skipped", skipped.Load()), slog.Int64("pruned", pruned.Load()), slog.Int("failed", report.len()))
 		}()
 
 		for scanner.Scan() {
@@ -123,37 +197,21 @@ var applyUsersCmd = &cobra.Command{
 			if line == "" || strings.HasPrefix(line, "#") {
 				continue
 			}
-			user := userpool.User{
-				Attributes:     map[string]any{},
-				ClientMetadata: map[string]string{},
+			if cols != "" && l <= skipHeader {
+				// CSV header
+				report.header = append(report.header, line)
+				continue
 			}
-			if cols == "" {
-				// AS JSONL
-				if err := json.Unmarshal([]byte(line), &user); err != nil {
-					return fmt.Errorf("line %d: %w", l, err)
+			user, err := parseUserLine(line)
+			if err != nil {
+				if err := rw.failed(l, user.Username, err, 0); err != nil {
+					return err
 				}
-			} else {
-				// CSV
-				if l <= skipHeader {
+				if continueOnError {
+					report.add(l, user.Username, line, err)
 					continue
 				}
-				keys := strings.Split(cols, ",")
-				fields := strings.Split(line, ",")
-				if len(keys) != len(fields) {
-					return fmt.Errorf("line %d: invalid format", l)
-				}
-				for i, key := range keys {
-					switch {
-					case key == "username":
-						user.Username = fields[i]
-					case key == "password":
-						user.Password = fields[i]
-					case key == "":
-						continue
-					default:
-						user.Attributes[key] = fields[i]
-					}
-				}
+				return fmt.Errorf("line %d: %w", l, err)
 			}
 
 			// additinal client metadata
@@ -164,10 +222,37 @@ var applyUsersCmd = &cobra.Command{
 					slog.Info("skip user", slog.String("username", user.Username))
 				}
 				skipped.Add(1)
+				if err := rw.skipped(l, user.Username); err != nil {
+					return err
+				}
+				continue
+			}
+			seen[user.Username] = struct{}{}
+			if username, ok := done[l]; ok && username == user.Username {
+				if verbose {
+					slog.Info("skip applied user", slog.String("username", user.Username))
+				}
+				skipped.Add(1)
+				if err := rw.skipped(l, user.Username); err != nil {
+					return err
+				}
+				continue
+			}
+			if plan {
+				p, err := up.PlanUser(ctx, user, opts...)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", l, err)
+				}
+				ps.add(p)
+				printUserPlan(cmd.OutOrStdout(), p)
 				continue
 			}
 			if verbose {
-				slog.Info("appliying user", slog.String("username", user.Username))
+				attrs := []any{slog.String("username", user.Username)}
+				if user.Enabled != nil {
+					attrs = append(attrs, slog.Bool("enabled", *user.Enabled))
+				}
+				slog.Info("appliying user", attrs...)
 			}
 			if dryRun {
 				applied.Add(1)
@@ -176,28 +261,125 @@ var applyUsersCmd = &cobra.Command{
 			select {
 			case <-ctx.Done():
 				continue
-			default:
+			case sem <- struct{}{}:
 			}
 
-			func(l int) {
+			func(l int, line string) {
 				donegroup.Go(ctx, func() error {
-					if err := up.ApplyUser(context.WithoutCancel(ctx), user, opts...); err != nil {
+					defer func() { <-sem }()
+					start := time.Now()
+					res, err := up.ApplyUserWithResult(context.WithoutCancel(ctx), user, opts...)
+					if err != nil {
+						if err := rw.failed(l, user.Username, err, time.Since(start)); err != nil {
+							cancel()
+							return err
+						}
+						if continueOnError {
+							report.add(l, user.Username, line, err)
+							return nil
+						}
 						cancel()
 						return fmt.Errorf("line %d: %w", l, err)
 					}
 					applied.Add(1)
+					if cp != nil {
+						if err := cp.record(l, user.Username); err != nil {
+							slog.Warn("failed to record checkpoint", slog.Int("line", l), slog.String("username", user.Username), slog.String("error", err.Error()))
+						}
+					}
+					if err := rw.applied(l, res, time.Since(start)); err != nil {
+						cancel()
+						return err
+					}
 					return nil
 				})
-			}(l)
+			}(l, line)
 
 		}
 		if err := scanner.Err(); err != nil {
 			return err
 		}
+		if plan {
+			if prune {
+				candidates, err := pruneCandidates(ctx, up, seen, filterRe, pruneAction)
+				if err != nil {
+					return err
+				}
+				for _, username := range candidates {
+					printPrunePlan(cmd.OutOrStdout(), ps, username, pruneAction)
+				}
+			}
+			ps.print(cmd.OutOrStdout())
+			if ps.pending() {
+				return errChangesPending
+			}
+			return nil
+		}
 		cancel()
 		if err := donegroup.Wait(ctx); err != nil {
 			return err
 		}
+		if n := report.len(); n > 0 {
+			report.summarize()
+			if !dryRun {
+				fp := failuresFile
+				if fp == "" {
+					fp = failuresPath(p)
+				}
+				if err := report.write(fp); err != nil {
+					return err
+				}
+				slog.Info("failures file written", slog.String("path", fp))
+			}
+			if prune {
+				slog.Warn("prune skipped because some users failed to apply")
+			}
+			return fmt.Errorf("%d users failed to apply", n)
+		}
+		if cp != nil {
+			// all users are applied
+			if err := cp.remove(); err != nil {
+				return err
+			}
+		}
+
+		if !prune {
+			return nil
+		}
+		ctx = context.WithoutCancel(ctx)
+		candidates, err := pruneCandidates(ctx, up, seen, filterRe, pruneAction)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+		if dryRun {
+			for _, username := range candidates {
+				slog.Info(fmt.Sprintf("dry-run: %s user", pruneAction), slog.String("username", username))
+				pruned.Add(1)
+			}
+			return nil
+		}
+		if !yes {
+			ok, err := confirmPrune(cmd.InOrStdin(), promptOut, "users", candidates, pruneAction)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				slog.Info("prune canceled")
+				return nil
+			}
+		}
+		for _, username := range candidates {
+			if verbose {
+				slog.Info(fmt.Sprintf("%s user", pruneAction), slog.String("username", username))
+			}
+			if err := pruneUser(ctx, up, username, pruneAction); err != nil {
+				return fmt.Errorf("%s user %s: %w", pruneAction, username, err)
+			}
+			pruned.Add(1)
+		}
 		return nil
 	},
 }
@@ -208,13 +390,94 @@ func init() {
 	applyUsersCmd.Flags().BoolVarP(&randomPassword, "random-password", "r", false, "set random password")
 	applyUsersCmd.Flags().BoolVarP(&permanentPassword, "permanent-password", "P", false, "set permanent password")
 	applyUsersCmd.Flags().BoolVarP(&sendPasswordResetCode, "send-password-reset-code", "s", false, "send password reset code")
+	applyUsersCmd.Flags().BoolVar(&reconcileGroups, "reconcile-groups", false, "remove users from groups not in the users file")
 	applyUsersCmd.Flags().StringVarP(&filter, "filter", "f", "", "filter apply users")
-	applyUsersCmd.Flags().StringVarP(&cols, "columns", "c", "", "define columns for CSV format")
+	applyUsersCmd.Flags().StringVarP(&cols, "columns", "c", "", "define columns for CSV format (empty cells are not applied)")
 	applyUsersCmd.Flags().IntVarP(&skipHeader, "skip-header", "S", 0, "count of CSV header lines to skip")
 	applyUsersCmd.Flags().StringVarP(&clientMetadata, "client-metadata", "m", "", "set client metadata")
 	applyUsersCmd.Flags().BoolVar(&dryRun, "dry-run", false, "dry run")
+	applyUsersCmd.Flags().BoolVar(&plan, "plan", false, "show changes to users without applying them. exit with status 2 if there are changes")
+	applyUsersCmd.MarkFlagsMutuallyExclusive("dry-run", "plan")
+	applyUsersCmd.Flags().BoolVar(&prune, "prune", false, "prune users in the user pool that are not in the users file")
+	applyUsersCmd.Flags().StringVar(&pruneAction, "prune-action", pruneActionDelete, "action for pruned users (delete|disable)")
+	applyUsersCmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation of pruning")
 	applyUsersCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	applyUsersCmd.Flags().StringVarP(&endpoint, "endpoint", "e", "", "set endpoint")
+	applyUsersCmd.Flags().IntVar(&concurrency, "concurrency", 10, "number of users applied concurrently")
+	applyUsersCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "continue applying the other users when a user fails to be applied, and write the failed users to the failures file")
+	applyUsersCmd.Flags().StringVar(&failuresFile, "failures-file", "", "path of the failures file for --continue-on-error. if not set, USERS_FILE with .failed before the extension (e.g. users.failed.jsonl)")
+	applyUsersCmd.Flags().BoolVar(&useCheckpoint, "checkpoint", false, "record applied users to the checkpoint in the state directory to resume an interrupted run with --resume")
+	applyUsersCmd.Flags().BoolVar(&resume, "resume", false, "skip users already applied by an interrupted run of the same users file (implies --checkpoint)")
+	applyUsersCmd.MarkFlagsMutuallyExclusive("resume", "plan")
+	applyUsersCmd.Flags().StringVarP(&resultOutput, "output", "o", "", "output format of the result of each user (jsonl). logs are written to stderr")
+	applyUsersCmd.MarkFlagsMutuallyExclusive("output", "plan")
+	applyUsersCmd.MarkFlagsMutuallyExclusive("output", "dry-run")
+	applyUsersCmd.Flags().Float64Var(&rateLimit, "rate", 0, "max requests per second of each API quota category. if 0, use the default quotas of Amazon Cognito")
+}
+
+// parseUserLine parses a line of the users file in JSONL, or in CSV if --columns is set.
+func parseUserLine(line string) (userpool.User, error) {
+	user := userpool.User{
+		Attributes:     map[string]any{},
+		ClientMetadata: map[string]string{},
+	}
+	if cols == "" {
+		// AS JSONL
+		if err := json.Unmarshal([]byte(line), &user); err != nil {
+			return user, err
+		}
+		return user, nil
+	}
+	// CSV
+	keys := strings.Split(cols, ",")
+	fields := strings.Split(line, ",")
+	if len(keys) != len(fields) {
+		return user, errors.New("invalid format")
+	}
+	for i, key := range keys {
+		switch {
+		case key == "username":
+			user.Username = fields[i]
+		case key == "password":
+			user.Password = fields[i]
+		case key == "groups":
+			if fields[i] == "" {
+				// users without groups are not touched
+				continue
+			}
+			user.Groups = parseGroups(fields[i])
+		case key == "enabled":
+			if fields[i] == "" {
+				continue
+			}
+			enabled, err := strconv.ParseBool(fields[i])
+			if err != nil {
+				return user, fmt.Errorf("invalid enabled value: %w", err)
+			}
+			user.Enabled = &enabled
+		case key == "":
+			continue
+		case fields[i] == "":
+			// empty attribute values are not applied
+			continue
+		default:
+			user.Attributes[key] = fields[i]
+		}
+	}
+	return user, nil
+}
+
+func parseGroups(in string) []string {
+	// group1|group2
+	groups := []string{}
+	for _, g := range strings.Split(in, "|") {
+		g = strings.TrimSpace(g)
+		if g == "" {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	return groups
 }
 
 func parseClientMetadata(in string) (map[string]string, error) {
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseUserLineGroups(t *testing.T) {
+	tests := []struct {
+		name string
+		cols string
+		line string
+		want []string
+	}{
+		{"CSV groups", "username,groups", "alice,admin|staff", []string{"admin", "staff"}},
+		{"CSV empty groups", "username,groups", "alice,", nil},
+		{"JSONL groups", "", `{"username":"alice","groups":["admin"]}`, []string{"admin"}},
+		{"JSONL empty groups", "", `{"username":"alice","groups":[]}`, []string{}},
+		{"JSONL without groups", "", `{"username":"alice"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cols = tt.cols
+			t.Cleanup(func() { cols = "" })
+			u, err := parseUserLine(tt.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (u.Groups == nil) != (tt.want == nil) || !slices.Equal(u.Groups, tt.want) {
+				t.Errorf("got %#v, want %#v", u.Groups, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUserLineEmptyCells(t *testing.T) {
+	cols = "username,email,phone_number,enabled,groups"
+	t.Cleanup(func() { cols = "" })
+	u, err := parseUserLine("alice,,+1234567890,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Username != "alice" {
+		t.Errorf("username: got %q, want %q", u.Username, "alice")
+	}
+	// empty cells are not applied
+	want := map[string]any{"phone_number": "+1234567890"}
+	if !maps.Equal(u.Attributes, want) {
+		t.Errorf("attributes: got %v, want %v", u.Attributes, want)
+	}
+	if u.Enabled != nil {
+		t.Errorf("enabled: got %v, want nil", *u.Enabled)
+	}
+	if u.Groups != nil {
+		t.Errorf("groups: got %v, want nil", u.Groups)
+	}
+}
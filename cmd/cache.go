@@ -0,0 +1,174 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/coglet/userpool/tokencache"
+	"github.com/spf13/cobra"
+)
+
+var cacheKeyFile string
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "manage the token cache of login-as",
+	Long:  `manage the token cache of login-as.`,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+}
+
+// legacyTokenCacheOnce removes the legacy token files once per process, as proxy creates the token cache for each user.
+var legacyTokenCacheOnce sync.Once
+
+// newTokenCache returns the token cache in the state directory.
+// The cache is encrypted when a key file or a passphrase is given.
+func newTokenCache(cmd *cobra.Command) (*tokencache.File, error) {
+	legacyTokenCacheOnce.Do(func() {
+		removeLegacyTokenCache(cmd.ErrOrStderr())
+	})
+	secret, keyFile, err := cacheSecret()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case keyFile:
+		return tokencache.NewEncryptedFileWithKey(cacheDir(), secret), nil
+	case secret != nil:
+		return tokencache.NewEncryptedFile(cacheDir(), secret), nil
+	}
+	return tokencache.NewFile(cacheDir()), nil
+}
+
+// newTOTPSecrets returns the store of TOTP secrets in the state directory.
+// The secrets are encrypted with the same key as the token cache.
+func newTOTPSecrets() (*tokencache.Secrets, error) {
+	secret, keyFile, err := cacheSecret()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case keyFile:
+		return tokencache.NewEncryptedSecretsWithKey(totpDir(), secret), nil
+	case secret != nil:
+		return tokencache.NewEncryptedSecrets(totpDir(), secret), nil
+	}
+	return tokencache.NewSecrets(totpDir()), nil
+}
+
+// cacheSecret returns the content of the key file, or the passphrase. It returns nil if neither is given.
+// The key is derived from the content of a key file by HKDF, and from a passphrase by PBKDF2.
+func cacheSecret() (secret []byte, keyFile bool, err error) {
+	if path := flagOrEnv(cacheKeyFile, "COGLET_CACHE_KEY_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, false, err
+		}
+		return bytes.TrimSpace(b), true, nil
+	}
+	if p := os.Getenv("COGLET_CACHE_PASSPHRASE"); p != "" {
+		return []byte(p), false, nil
+	}
+	return nil, false, nil
+}
+
+// legacyTokenCacheMarker is created in the state directory once the legacy token files are removed.
+const legacyTokenCacheMarker = ".legacy-token-cache-removed"
+
+// removeLegacyTokenCache removes the token files stored directly in the state directory by older versions,
+// and creates the marker so that it does not run again.
+// They are keyed only by the user pool and the username, so they cannot be migrated to the current cache.
+// Only files in the old format are removed, and the result is reported to w (stderr) to keep stdout for the tokens.
+func removeLegacyTokenCache(w io.Writer) {
+	marker := filepath.Join(statePath(), legacyTokenCacheMarker)
+	if _, err := os.Stat(marker); err == nil {
+		return
+	}
+	if _, err := os.Stat(statePath()); err != nil {
+		// no state directory, no legacy token files
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(statePath(), "*.json"))
+	if err != nil {
+		return
+	}
+	failed := false
+	for _, f := range files {
+		if !isLegacyTokenCache(f) {
+			continue
+		}
+		if err := os.Remove(f); err != nil {
+			_, _ = fmt.Fprintf(w, "failed to remove legacy token cache %s: %v\n", f, err)
+			failed = true
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "legacy token cache removed: %s\n", f)
+	}
+	if failed {
+		// retry next time
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0600); err != nil {
+		_, _ = fmt.Fprintf(w, "failed to create %s: %v\n", marker, err)
+	}
+}
+
+// isLegacyTokenCache reports whether the file is a token file of older versions,
+// which is a JSON object of only expired_at and Auth.
+func isLegacyTokenCache(path string) bool {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return false
+	}
+	_, hasExpiredAt := fields["expired_at"]
+	_, hasAuth := fields["Auth"]
+	return len(fields) == 2 && hasExpiredAt && hasAuth
+}
+
+func cacheStatus(t *userpool.CachedToken) string {
+	switch {
+	case !t.Expired():
+		return "valid"
+	case t.Refreshable():
+		return "refreshable"
+	default:
+		return "expired"
+	}
+}
+
+func cacheDir() string {
+	return filepath.Join(statePath(), "tokens")
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+var expiredOnly bool
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "clear the cached tokens",
+	Long:  `clear the cached tokens.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		tc, err := newTokenCache(cmd)
+		if err != nil {
+			return err
+		}
+		if !expiredOnly {
+			cleared, err := tc.Clear(ctx)
+			if err != nil {
+				return err
+			}
+			slog.Info("cache cleared", slog.Int("total", cleared))
+			return nil
+		}
+		tokens, err := tc.List(ctx)
+		if err != nil {
+			return err
+		}
+		var cleared int
+		for _, t := range tokens {
+			if !t.Expired() {
+				continue
+			}
+			if err := tc.Delete(ctx, t.TokenCacheKey); err != nil {
+				return err
+			}
+			cleared++
+		}
+		slog.Info("cache cleared", slog.Int("total", cleared))
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheClearCmd.Flags().BoolVarP(&expiredOnly, "expired", "", false, "clear only expired tokens, including those that can be renewed with the refresh token")
+	cacheClearCmd.Flags().StringVarP(&cacheKeyFile, "cache-key-file", "", "", "key file to decrypt the token cache. if not set, use COGLET_CACHE_KEY_FILE env or COGLET_CACHE_PASSPHRASE env")
+}
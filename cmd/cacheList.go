@@ -0,0 +1,59 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/k1LoW/coglet/userpool/tokencache"
+	"github.com/spf13/cobra"
+)
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the cached tokens",
+	Long:  `list the cached tokens with the user pool, the app client, the auth flow and the expiry.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tc, err := newTokenCache(cmd)
+		if err != nil {
+			return err
+		}
+		tokens, err := tc.List(cmd.Context())
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tUSER_POOL_ID\tCLIENT_ID\tUSERNAME\tAUTH_FLOW\tEXPIRES_AT\tSTATUS")
+		for _, t := range tokens {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", tokencache.ID(t.TokenCacheKey), t.UserPoolID, t.ClientID, t.Username, t.AuthFlow, time.Unix(t.ExpiredAt, 0).Format(time.RFC3339), cacheStatus(t))
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheListCmd.Flags().StringVarP(&cacheKeyFile, "cache-key-file", "", "", "key file to decrypt the token cache. if not set, use COGLET_CACHE_KEY_FILE env or COGLET_CACHE_PASSPHRASE env")
+}
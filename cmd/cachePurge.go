@@ -0,0 +1,79 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var cachePurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "purge the state directory",
+	Long:  `purge the state directory including the cached tokens and the stored TOTP secrets.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := os.Stat(statePath()); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !yes {
+			ok, err := confirmPurge(cmd.InOrStdin(), cmd.ErrOrStderr(), statePath())
+			if err != nil {
+				return err
+			}
+			if !ok {
+				slog.Info("purge canceled")
+				return nil
+			}
+		}
+		if err := os.RemoveAll(statePath()); err != nil {
+			return err
+		}
+		slog.Info("state directory purged", slog.String("path", statePath()))
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cachePurgeCmd)
+	cachePurgeCmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip confirmation of purging")
+}
+
+func confirmPurge(in io.Reader, out io.Writer, path string) (bool, error) {
+	_, _ = fmt.Fprintf(out, "%s including the cached tokens and the stored TOTP secrets will be deleted.\n", path)
+	_, _ = fmt.Fprint(out, "Do you want to purge it? Only 'yes' will be accepted: ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return strings.TrimSpace(line) == "yes", nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/spf13/cobra"
+)
+
+var cacheShowCmd = &cobra.Command{
+	Use:   "show [ID]",
+	Short: "show the cached tokens",
+	Long:  `show the cached tokens of the ID listed by cache list.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tc, err := newTokenCache(cmd)
+		if err != nil {
+			return err
+		}
+		t, err := tc.GetByID(cmd.Context(), args[0])
+		if err != nil {
+			if errors.Is(err, userpool.ErrTokenCacheMiss) {
+				return fmt.Errorf("token cache not found: %s", args[0])
+			}
+			return err
+		}
+		b, err := json.Marshal(struct {
+			*userpool.CachedToken
+			Status string `json:"status"`
+		}{
+			CachedToken: t,
+			Status:      cacheStatus(t),
+		})
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), string(b))
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheShowCmd)
+	cacheShowCmd.Flags().StringVarP(&cacheKeyFile, "cache-key-file", "", "", "key file to decrypt the token cache. if not set, use COGLET_CACHE_KEY_FILE env or COGLET_CACHE_PASSPHRASE env")
+}
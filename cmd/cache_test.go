@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/coglet/userpool/tokencache"
+)
+
+func TestRemoveLegacyTokenCache(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	legacy := filepath.Join(statePath(), "ap-northeast-1_abcdefgh_alice.json")
+	other := filepath.Join(statePath(), "other.json")
+	current := filepath.Join(cacheDir(), tokencache.ID(userpool.TokenCacheKey{UserPoolID: "ap-northeast-1_abcdefgh", ClientID: "client", Username: "alice", AuthFlow: "USER_SRP_AUTH"})+".json")
+	files := map[string]string{
+		legacy:  `{"expired_at":1700000000,"Auth":{"AuthenticationResult":{"IdToken":"token"}}}`,
+		other:   `{"expired_at":1700000000}`,
+		current: `{"user_pool_id":"ap-northeast-1_abcdefgh","client_id":"client","username":"alice","auth_flow":"USER_SRP_AUTH","expired_at":1700000000,"Auth":null}`,
+	}
+	for p, content := range files {
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stderr := &bytes.Buffer{}
+	removeLegacyTokenCache(stderr)
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("legacy token cache is not removed: %v", err)
+	}
+	for _, p := range []string{other, current} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s is removed: %v", p, err)
+		}
+	}
+	if !strings.Contains(stderr.String(), legacy) {
+		t.Errorf("got %q, want the removed file reported", stderr.String())
+	}
+
+	// it runs only once
+	if err := os.WriteFile(legacy, []byte(files[legacy]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	removeLegacyTokenCache(io.Discard)
+	if _, err := os.Stat(legacy); err != nil {
+		t.Errorf("legacy token cache is removed again: %v", err)
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantExists bool
+	}{
+		{"canceled", "no\n", true},
+		{"no input", "", true},
+		{"confirmed", "yes\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+			if err := os.MkdirAll(cacheDir(), 0700); err != nil {
+				t.Fatal(err)
+			}
+			cachePurgeCmd.SetIn(strings.NewReader(tt.input))
+			cachePurgeCmd.SetErr(io.Discard)
+			if err := cachePurgeCmd.RunE(cachePurgeCmd, nil); err != nil {
+				t.Fatal(err)
+			}
+			_, err := os.Stat(statePath())
+			if exists := err == nil; exists != tt.wantExists {
+				t.Errorf("got exists %v, want %v", exists, tt.wantExists)
+			}
+		})
+	}
+}
+
+func TestCachedToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyFile bool
+	}{
+		{"plain", false},
+		{"key file", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+			t.Setenv("COGLET_CACHE_KEY_FILE", "")
+			t.Setenv("COGLET_CACHE_PASSPHRASE", "")
+			if tt.keyFile {
+				p := filepath.Join(t.TempDir(), "key")
+				if err := os.WriteFile(p, []byte(strings.Repeat("k", 32)+"\n"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("COGLET_CACHE_KEY_FILE", p)
+			}
+			cmd, _, _ := newTestCommand(t, true)
+			tc, err := newTokenCache(cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := userpool.TokenCacheKey{UserPoolID: "ap-northeast-1_abcdefgh", ClientID: "client", Username: "alice", AuthFlow: "USER_SRP_AUTH"}
+			if err := tc.Set(cmd.Context(), &userpool.CachedToken{
+				TokenCacheKey: key,
+				Auth:          &cognito.InitiateAuthOutput{AuthenticationResult: &types.AuthenticationResultType{IdToken: aws.String("id-token")}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			got, err := cachedToken(cmd, tokencache.ID(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aws.ToString(got.Auth.AuthenticationResult.IdToken) != "id-token" {
+				t.Errorf("got %+v", got.Auth.AuthenticationResult)
+			}
+			if _, err := cachedToken(cmd, "unknown"); err == nil {
+				t.Error("want error for unknown ID")
+			}
+		})
+	}
+}
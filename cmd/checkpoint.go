@@ -0,0 +1,148 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// checkpointEntry is a user in the users file that has been applied.
+type checkpointEntry struct {
+	Line     int    `json:"line"`
+	Username string `json:"username"`
+}
+
+// checkpoint records the users applied by apply-users to resume an interrupted run.
+type checkpoint struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// checkpointPath returns the path of the checkpoint of the users file with the content hash for the user pool.
+func checkpointPath(userPoolID, hash string) string {
+	r := strings.NewReplacer(":", "_", "/", "_")
+	return filepath.Join(statePath(), "checkpoints", fmt.Sprintf("%s_%s.jsonl", r.Replace(userPoolID), hash))
+}
+
+// contentHash returns the SHA-256 hash of the content of f, and rewinds f.
+func contentHash(f *os.File) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadCheckpoint returns the usernames of the applied lines. It returns an empty map if the checkpoint does not exist.
+func loadCheckpoint(p string) (map[int]string, error) {
+	done := map[int]string{}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return done, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e checkpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// the last entry may be partially written when interrupted
+			continue
+		}
+		done[e.Line] = e.Username
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+func openCheckpoint(p string) (*checkpoint, error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// terminate the entry partially written when interrupted
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if fi.Size() > 0 {
+		b := make([]byte, 1)
+		if _, err := f.ReadAt(b, fi.Size()-1); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if b[0] != '\n' {
+			if _, err := f.Write([]byte("\n")); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+		}
+	}
+	return &checkpoint{f: f}, nil
+}
+
+func (c *checkpoint) record(l int, username string) error {
+	b, err := json.Marshal(checkpointEntry{Line: l, Username: username})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.f.Write(append(b, '\n'))
+	return err
+}
+
+func (c *checkpoint) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.f.Close()
+}
+
+// remove closes and deletes the checkpoint after all users are applied.
+func (c *checkpoint) remove() error {
+	if err := c.close(); err != nil {
+		return err
+	}
+	return os.Remove(c.f.Name())
+}
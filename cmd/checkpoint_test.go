@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	p := checkpointPath("ap-northeast-1_abcdefgh", "0123abcd")
+	if want := filepath.Join(statePath(), "checkpoints", "ap-northeast-1_abcdefgh_0123abcd.jsonl"); p != want {
+		t.Errorf("got %s, want %s", p, want)
+	}
+
+	done, err := loadCheckpoint(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 0 {
+		t.Errorf("got %v, want empty", done)
+	}
+
+	c, err := openCheckpoint(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for l, username := range map[int]string{1: "alice", 3: "carol"} {
+		if err := c.record(l, username); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+	done, err = loadCheckpoint(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]string{1: "alice", 3: "carol"}; !maps.Equal(done, want) {
+		t.Errorf("got %v, want %v", done, want)
+	}
+
+	// resume after an interrupted run
+	c, err = openCheckpoint(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.record(2, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("checkpoint is not removed: %v", err)
+	}
+}
+
+func TestCheckpointPartiallyWritten(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	p := checkpointPath("ap-northeast-1_abcdefgh", "0123abcd")
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		t.Fatal(err)
+	}
+	// the last entry is interrupted while writing
+	if err := os.WriteFile(p, []byte("{\"line\":1,\"username\":\"alice\"}\n{\"line\":2,\"user"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	done, err := loadCheckpoint(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]string{1: "alice"}; !maps.Equal(done, want) {
+		t.Errorf("got %v, want %v", done, want)
+	}
+	c, err := openCheckpoint(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.record(2, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+	done, err = loadCheckpoint(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]string{1: "alice", 2: "bob"}; !maps.Equal(done, want) {
+		t.Errorf("got %v, want %v", done, want)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "users.jsonl")
+	content := "{\"username\":\"alice\"}\n"
+	if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := contentHash(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sha256sum of the content
+	if want := "a47295da305d84a98794a73a6af8450b7b86f9208a40125a6eb4fff47e788a7b"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	// f is rewound
+	b := make([]byte, len(content))
+	if _, err := f.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Errorf("got %q, want %q", b, content)
+	}
+}
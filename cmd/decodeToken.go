@@ -0,0 +1,262 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/k1LoW/coglet/internal/jwt"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fromCache   string
+	tokenUse    string
+	verifyToken bool
+	jwksSource  string
+	userPoolID  string
+	clientID    string
+)
+
+// cognitoIssuerRe matches the issuer of the tokens of Amazon Cognito user pools.
+var cognitoIssuerRe = regexp.MustCompile(`^https://cognito-idp\.[a-z0-9-]+\.amazonaws\.com/[a-z0-9-]+_[A-Za-z0-9]+$`)
+
+// timestampClaims are the NumericDate claims shown in a human-readable form.
+var timestampClaims = []string{"exp", "iat", "nbf", "auth_time"}
+
+var decodeTokenCmd = &cobra.Command{
+	Use:   "decode-token [TOKEN]",
+	Short: "decode and verify a token",
+	Long:  `decode a token issued by the user pool and optionally verify it. The token is read from the argument, stdin or the token cache.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if tokenUse != "" && tokenUse != "id" && tokenUse != "access" {
+			return fmt.Errorf("invalid token use: %s", tokenUse)
+		}
+		var raw string
+		switch {
+		case fromCache != "":
+			c, err := cachedToken(cmd, fromCache)
+			if err != nil {
+				return err
+			}
+			if userPoolID == "" {
+				userPoolID = c.UserPoolID
+			}
+			if clientID == "" {
+				clientID = c.ClientID
+			}
+			if tokenUse == "" {
+				tokenUse = "id"
+			}
+			raw = aws.ToString(c.Auth.AuthenticationResult.IdToken)
+			if tokenUse == "access" {
+				raw = aws.ToString(c.Auth.AuthenticationResult.AccessToken)
+			}
+		case len(args) == 1 && args[0] != "-":
+			raw = args[0]
+		default:
+			b, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			raw = string(b)
+		}
+		t, err := jwt.Parse(raw)
+		if err != nil {
+			return err
+		}
+
+		var (
+			verified  *bool
+			verifyErr error
+		)
+		if verifyToken {
+			verifyErr = verify(ctx, t)
+			verified = aws.Bool(verifyErr == nil)
+		}
+		timestamps := map[string]string{}
+		for _, name := range timestampClaims {
+			if v, ok := t.Time(name); ok {
+				timestamps[name] = humanTime(v)
+			}
+		}
+		b, err := json.MarshalIndent(struct {
+			Header     map[string]any    `json:"header"`
+			Claims     map[string]any    `json:"claims"`
+			Timestamps map[string]string `json:"timestamps"`
+			Verified   *bool             `json:"verified,omitempty"`
+		}{
+			Header:     t.Header,
+			Claims:     t.Claims,
+			Timestamps: timestamps,
+			Verified:   verified,
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), string(b))
+		if verifyErr != nil {
+			return fmt.Errorf("verification failed: %w", verifyErr)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(decodeTokenCmd)
+	decodeTokenCmd.Flags().StringVarP(&fromCache, "from-cache", "", "", "read the token from the token cache of the ID listed by cache list")
+	decodeTokenCmd.Flags().StringVarP(&tokenUse, "token-use", "", "", "token use (id|access). selects the token read from the cache, and is verified with --verify")
+	decodeTokenCmd.Flags().BoolVarP(&verifyToken, "verify", "", false, "verify the signature, the expiry, and the issuer, the audience and the token use if known")
+	decodeTokenCmd.Flags().StringVarP(&jwksSource, "jwks", "", "", "JWKS file or https URL to verify the signature. if not set, fetch it from the issuer of --user-pool-id")
+	decodeTokenCmd.Flags().StringVarP(&userPoolID, "user-pool-id", "", "", "user pool ID to verify the issuer. --verify requires it or --jwks")
+	decodeTokenCmd.Flags().StringVarP(&clientID, "client-id", "", "", "app client ID to verify the audience (aud or client_id)")
+	decodeTokenCmd.Flags().StringVarP(&cacheKeyFile, "cache-key-file", "", "", "key file to decrypt the token cache. if not set, use COGLET_CACHE_KEY_FILE env or COGLET_CACHE_PASSPHRASE env")
+}
+
+func cachedToken(cmd *cobra.Command, id string) (*userpool.CachedToken, error) {
+	tc, err := newTokenCache(cmd)
+	if err != nil {
+		return nil, err
+	}
+	t, err := tc.GetByID(cmd.Context(), id)
+	if err != nil {
+		if errors.Is(err, userpool.ErrTokenCacheMiss) {
+			return nil, fmt.Errorf("token cache not found: %s", id)
+		}
+		return nil, err
+	}
+	if t.Auth == nil || t.Auth.AuthenticationResult == nil {
+		return nil, fmt.Errorf("no tokens in the token cache: %s", id)
+	}
+	return t, nil
+}
+
+// verify verifies the token with the flags.
+// The issuer of the token is not trusted, so either the user pool or the JWKS must be given.
+func verify(ctx context.Context, t *jwt.Token) error {
+	if userPoolID == "" && jwksSource == "" {
+		return errors.New("set --user-pool-id or --jwks to verify the token")
+	}
+	issuer := t.String("iss")
+	if userPoolID != "" {
+		expected := cognitoIssuer(userPoolID)
+		if issuer != expected {
+			return fmt.Errorf("invalid issuer: %s, expected %s", issuer, expected)
+		}
+	}
+	if jwksSource == "" && !cognitoIssuerRe.MatchString(issuer) {
+		// the JWKS is fetched from the issuer
+		return fmt.Errorf("invalid issuer: %s", issuer)
+	}
+	jwks, err := loadJWKS(ctx, issuer)
+	if err != nil {
+		return err
+	}
+	if err := t.Verify(jwks); err != nil {
+		return err
+	}
+	exp, ok := t.Time("exp")
+	if !ok {
+		return errors.New("no exp claim")
+	}
+	if exp.Before(time.Now()) {
+		return fmt.Errorf("token expired at %s", exp.Format(time.RFC3339))
+	}
+	use := t.String("token_use")
+	if tokenUse != "" && use != tokenUse {
+		return fmt.Errorf("invalid token use: %s, expected %s", use, tokenUse)
+	}
+	if clientID != "" {
+		aud := t.String("aud")
+		if use == "access" {
+			aud = t.String("client_id")
+		}
+		if aud != clientID {
+			return fmt.Errorf("invalid audience: %s, expected %s", aud, clientID)
+		}
+	}
+	return nil
+}
+
+// loadJWKS loads the JWKS from --jwks, or fetches it from the issuer.
+func loadJWKS(ctx context.Context, issuer string) (*jwt.JWKS, error) {
+	src := jwksSource
+	if src == "" {
+		src = strings.TrimSuffix(issuer, "/") + "/.well-known/jwks.json"
+	}
+	if strings.HasPrefix(src, "http://") {
+		return nil, fmt.Errorf("JWKS URL must be https: %s", src)
+	}
+	if !strings.HasPrefix(src, "https://") {
+		b, err := os.ReadFile(src)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.ParseJWKS(b)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS from %s: %s", src, res.Status)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseJWKS(b)
+}
+
+// cognitoIssuer returns the issuer of the tokens of the user pool. The region is the prefix of the user pool ID.
+func cognitoIssuer(userPoolID string) string {
+	region, _, _ := strings.Cut(userPoolID, "_")
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID)
+}
+
+func humanTime(t time.Time) string {
+	d := time.Until(t).Round(time.Second)
+	if d < 0 {
+		return fmt.Sprintf("%s (%s ago)", t.UTC().Format(time.RFC3339), -d)
+	}
+	return fmt.Sprintf("%s (in %s)", t.UTC().Format(time.RFC3339), d)
+}
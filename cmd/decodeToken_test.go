@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/internal/jwt"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/coglet/userpool/userpooltest"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	api := userpooltest.NewAPI()
+	id := api.CreateUserPool("test")
+	cid, err := api.CreateUserPoolClient(id, "app", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.SetExplicitAuthFlows(id, cid, types.ExplicitAuthFlowsTypeAllowUserPasswordAuth); err != nil {
+		t.Fatal(err)
+	}
+	up, err := userpool.New(id, userpool.WithAPI(api))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+		t.Fatal(err)
+	}
+	out, err := up.LoginAs(ctx, userpool.User{Username: "alice", Password: "Passw0rd!"}, userpool.WithClientIDOrName(cid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk, err := jwt.Parse(aws.ToString(out.AuthenticationResult.IdToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksFile, api.JWKS(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		userPoolID string
+		jwks       string
+		clientID   string
+		tokenUse   string
+		wantErr    bool
+	}{
+		{"user pool and JWKS file", id, jwksFile, cid, "id", false},
+		{"JWKS file only", "", jwksFile, "", "", false},
+		{"neither user pool nor JWKS", "", "", "", "", true},
+		{"other user pool", "local_other", jwksFile, "", "", true},
+		{"other client", id, jwksFile, "other", "", true},
+		{"other token use", id, jwksFile, "", "access", true},
+		{"http JWKS URL", "", "http://example.com/jwks.json", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userPoolID, jwksSource, clientID, tokenUse = tt.userPoolID, tt.jwks, tt.clientID, tt.tokenUse
+			t.Cleanup(func() {
+				userPoolID, jwksSource, clientID, tokenUse = "", "", "", ""
+			})
+			err := verify(ctx, tk)
+			if tt.wantErr && err == nil {
+				t.Error("want error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCognitoIssuerRe(t *testing.T) {
+	tests := []struct {
+		issuer string
+		want   bool
+	}{
+		{"https://cognito-idp.ap-northeast-1.amazonaws.com/ap-northeast-1_AbCdEf123", true},
+		{cognitoIssuer("us-east-1_abc123"), true},
+		{"http://cognito-idp.ap-northeast-1.amazonaws.com/ap-northeast-1_AbCdEf123", false},
+		{"https://cognito-idp.ap-northeast-1.amazonaws.com.evil.example/ap-northeast-1_AbCdEf123", false},
+		{"https://evil.example/ap-northeast-1_AbCdEf123", false},
+		{"https://cognito-idp.ap-northeast-1.amazonaws.com/ap-northeast-1_AbC/../x", false},
+		{"https://cognito-idp.ap-northeast-1.amazonaws.com/ap-northeast-1_AbC?x=1", false},
+		{cognitoIssuer("evil.example/x_abc"), false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := cognitoIssuerRe.MatchString(tt.issuer); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.issuer, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeToken(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := unsignedToken(exp)
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+	}{
+		{"argument", []string{token}, ""},
+		{"stdin", nil, token + "\n"},
+		{"stdin with -", []string{"-"}, token},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, stdout, _ := newTestCommand(t, false)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			if err := decodeTokenCmd.RunE(cmd, tt.args); err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				Header     map[string]any    `json:"header"`
+				Claims     map[string]any    `json:"claims"`
+				Timestamps map[string]string `json:"timestamps"`
+			}
+			if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+				t.Fatalf("%v: %s", err, stdout.String())
+			}
+			if got.Header["alg"] != "RS256" || got.Claims["exp"] != float64(exp.Unix()) || got.Timestamps["exp"] == "" {
+				t.Errorf("got %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/spf13/cobra"
+)
+
+// reloginRetryInterval is the interval to retry renewing the tokens after a failure.
+const reloginRetryInterval = 30 * time.Second
+
+var (
+	idTokenEnv      string
+	accessTokenEnv  string
+	refreshTokenEnv string
+	relogin         bool
+	execUseCache    bool
+)
+
+var forwardSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+var execCmd = &cobra.Command{
+	Use:   "exec [USER_POOL_ID_OR_NAME] [USERNAME] -- [COMMAND] [ARGS...]",
+	Short: "run a command with the tokens of the user in its environment",
+	Long:  `run a command with the tokens of the user in its environment.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() != 2 || len(args) < 3 {
+			return errors.New("requires USER_POOL_ID_OR_NAME and USERNAME, and COMMAND after --")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		idOrName := args[0]
+		username := args[1]
+		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint))
+		if err != nil {
+			return err
+		}
+		return runExec(cmd, up, username, args[2:])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+	execCmd.Flags().StringVarP(&password, "password", "p", "", "password. if not set, use COGLET_PASSWORD env")
+	execCmd.Flags().StringVarP(&client, "client", "c", "", "user pool client id or name")
+	execCmd.Flags().StringVarP(&clientSecret, "client-secret", "", "", "user pool client secret. if not set, use COGLET_CLIENT_SECRET env or get it from the user pool client")
+	execCmd.Flags().StringVarP(&authFlow, "auth-flow", "", "", "auth flow (USER_PASSWORD_AUTH|USER_SRP_AUTH). if not set, select from the explicit auth flows of the user pool client")
+	execCmd.Flags().StringVarP(&totpSecret, "totp-secret", "", "", "base32 encoded TOTP secret for the SOFTWARE_TOKEN_MFA challenge. if not set, use COGLET_TOTP_SECRET env or the stored secret")
+	execCmd.Flags().StringVarP(&clientMetadata, "client-metadata", "m", "", "set client metadata")
+	execCmd.Flags().StringVarP(&endpoint, "endpoint", "e", "", "set endpoint")
+	execCmd.Flags().BoolVarP(&execUseCache, "use-cache", "", true, "use cache")
+	execCmd.Flags().StringVarP(&cacheKeyFile, "cache-key-file", "", "", "key file to encrypt the token cache. if not set, use COGLET_CACHE_KEY_FILE env or COGLET_CACHE_PASSPHRASE env")
+	execCmd.Flags().StringVarP(&idTokenEnv, "id-token-env", "", "COGLET_ID_TOKEN", "environment variable name for the ID token. if empty, the ID token is not set")
+	execCmd.Flags().StringVarP(&accessTokenEnv, "access-token-env", "", "COGLET_ACCESS_TOKEN", "environment variable name for the access token. if empty, the access token is not set")
+	execCmd.Flags().StringVarP(&refreshTokenEnv, "refresh-token-env", "", "COGLET_REFRESH_TOKEN", "environment variable name for the refresh token. if empty, the refresh token is not set")
+	execCmd.Flags().BoolVarP(&relogin, "relogin", "", false, "renew the tokens before they expire and write them to the file of COGLET_TOKEN_FILE env")
+}
+
+// runExec runs the command with the tokens of the user in its environment.
+func runExec(cmd *cobra.Command, up *userpool.Client, username string, command []string) error {
+	out, err := loginAs(cmd, up, username)
+	if err != nil {
+		return err
+	}
+	tokens := newTokenOutput(out.AuthenticationResult)
+
+	env := os.Environ()
+	for _, kv := range [][2]string{
+		{idTokenEnv, tokens.IDToken},
+		{accessTokenEnv, tokens.AccessToken},
+		{refreshTokenEnv, tokens.RefreshToken},
+	} {
+		if kv[0] == "" || kv[1] == "" {
+			continue
+		}
+		env = append(env, fmt.Sprintf("%s=%s", kv[0], kv[1]))
+	}
+	var tokenFile string
+	if relogin {
+		// the command writes its output to stdout
+		slog.SetDefault(slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil)))
+		dir, err := os.MkdirTemp("", "coglet-exec-")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = os.RemoveAll(dir)
+		}()
+		tokenFile = filepath.Join(dir, "token.json")
+		if err := writeTokenFile(tokenFile, tokens); err != nil {
+			return err
+		}
+		env = append(env, fmt.Sprintf("COGLET_TOKEN_FILE=%s", tokenFile))
+	}
+
+	c := exec.Command(command[0], command[1:]...) //nolint:gosec
+	c.Env = env
+	c.Stdin = cmd.InOrStdin()
+	c.Stdout = cmd.OutOrStdout()
+	c.Stderr = cmd.ErrOrStderr()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, forwardSignals...)
+	defer signal.Stop(sigs)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+
+	var renew <-chan time.Time
+	if relogin {
+		renew = time.After(renewIn(tokens))
+	}
+	for {
+		select {
+		case s := <-sigs:
+			_ = c.Process.Signal(s)
+		case <-renew:
+			renewed, err := renewTokens(cmd, up, username, &password, tokens)
+			if err != nil {
+				slog.Error("failed to renew tokens", slog.String("username", username), slog.String("error", err.Error()))
+				renew = time.After(reloginRetryInterval)
+				continue
+			}
+			if err := writeTokenFile(tokenFile, renewed); err != nil {
+				slog.Error("failed to write tokens", slog.String("path", tokenFile), slog.String("error", err.Error()))
+				renew = time.After(reloginRetryInterval)
+				continue
+			}
+			tokens = renewed
+			renew = time.After(renewIn(tokens))
+		case err := <-done:
+			var ee *exec.ExitError
+			if errors.As(err, &ee) {
+				// the error has been reported by the command itself
+				cmd.SilenceErrors = true
+				return &exitError{code: exitCode(ee)}
+			}
+			return err
+		}
+	}
+}
+
+// renewIn returns the duration until the tokens should be renewed, which is when 80% of their lifetime has elapsed.
+func renewIn(tokens tokenOutput) time.Duration {
+	return time.Duration(tokens.ExpiresIn) * time.Second * 4 / 5
+}
+
+// renewTokens renews the tokens with the refresh token, or logs in again if there is no refresh token.
+func renewTokens(cmd *cobra.Command, up *userpool.Client, username string, password *string, tokens tokenOutput) (tokenOutput, error) {
+	if tokens.RefreshToken == "" {
+		out, err := loginAsWithPassword(cmd, up, username, password)
+		if err != nil {
+			return tokenOutput{}, err
+		}
+		return newTokenOutput(out.AuthenticationResult), nil
+	}
+	opts, err := loginAsOptions(cmd, up, username)
+	if err != nil {
+		return tokenOutput{}, err
+	}
+	out, err := up.RefreshToken(cmd.Context(), username, tokens.RefreshToken, opts...)
+	if err != nil {
+		return tokenOutput{}, err
+	}
+	renewed := newTokenOutput(out.AuthenticationResult)
+	if renewed.RefreshToken == "" {
+		renewed.RefreshToken = tokens.RefreshToken
+	}
+	return renewed, nil
+}
+
+// writeTokenFile writes the tokens in the JSON schema of --output json, replacing the file atomically.
+func writeTokenFile(path string, tokens tokenOutput) error {
+	b, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func exitCode(ee *exec.ExitError) int {
+	if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ee.ExitCode()
+}
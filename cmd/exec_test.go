@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/k1LoW/coglet/userpool"
+)
+
+func TestRunExec(t *testing.T) {
+	ctx := context.Background()
+	_, _, up, clientID := newTestUserPool(t)
+	if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+		t.Fatal(err)
+	}
+	setLoginAsFlags(t, clientID)
+	password = "Passw0rd!"
+	defaultLogger := slog.Default()
+	saved := []string{idTokenEnv, accessTokenEnv, refreshTokenEnv}
+	savedRelogin := relogin
+	t.Cleanup(func() {
+		idTokenEnv, accessTokenEnv, refreshTokenEnv = saved[0], saved[1], saved[2]
+		relogin = savedRelogin
+		slog.SetDefault(defaultLogger)
+	})
+
+	t.Run("set the tokens in the environment", func(t *testing.T) {
+		idTokenEnv, accessTokenEnv, refreshTokenEnv = "COGLET_ID_TOKEN", "ACCESS", ""
+		cmd, stdout, _ := newTestCommand(t, false)
+		if err := runExec(cmd, up, "alice", []string{"sh", "-c", `printf '%s\n%s\n%s\n' "$COGLET_ID_TOKEN" "$ACCESS" "${COGLET_REFRESH_TOKEN-unset}"`}); err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		if len(got) != 3 || got[0] == "" || got[1] == "" || got[0] == got[1] {
+			t.Fatalf("got %q, want the ID token and the access token", got)
+		}
+		if got[2] != "unset" {
+			t.Errorf("got refresh token %q, want unset", got[2])
+		}
+	})
+
+	t.Run("forward the exit code", func(t *testing.T) {
+		cmd, _, _ := newTestCommand(t, false)
+		err := runExec(cmd, up, "alice", []string{"sh", "-c", "exit 3"})
+		var ee *exitError
+		if !errors.As(err, &ee) || ee.code != 3 {
+			t.Fatalf("got %v, want exit code 3", err)
+		}
+		if !cmd.SilenceErrors {
+			t.Error("want the error to be silenced")
+		}
+	})
+
+	t.Run("the command is not found", func(t *testing.T) {
+		cmd, _, _ := newTestCommand(t, false)
+		err := runExec(cmd, up, "alice", []string{"coglet-command-not-found"})
+		var ee *exitError
+		if err == nil || errors.As(err, &ee) {
+			t.Errorf("got %v, want the error of starting the command", err)
+		}
+	})
+
+	t.Run("write the token file with --relogin", func(t *testing.T) {
+		relogin = true
+		t.Cleanup(func() { relogin = false })
+		idTokenEnv = "COGLET_ID_TOKEN"
+		cmd, stdout, stderr := newTestCommand(t, false)
+		if err := runExec(cmd, up, "alice", []string{"sh", "-c", `cat "$COGLET_TOKEN_FILE"; echo; echo "$COGLET_ID_TOKEN"`}); err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		if len(got) != 2 {
+			t.Fatalf("got %q", stdout.String())
+		}
+		var o tokenOutput
+		if err := json.Unmarshal([]byte(got[0]), &o); err != nil {
+			t.Fatal(err)
+		}
+		if o.Version != tokenOutputVersion || o.IDToken != got[1] || o.RefreshToken == "" {
+			t.Errorf("got %+v", o)
+		}
+		// the errors of renewing the tokens are logged to stderr, not to the output of the command
+		slog.Error("failed to renew tokens")
+		if !strings.Contains(stderr.String(), "failed to renew tokens") {
+			t.Errorf("got stderr %q", stderr.String())
+		}
+	})
+}
+
+func TestRenewTokens(t *testing.T) {
+	ctx := context.Background()
+	_, _, up, clientID := newTestUserPool(t)
+	if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+		t.Fatal(err)
+	}
+	setLoginAsFlags(t, clientID)
+	pw := "Passw0rd!"
+	cmd, _, _ := newTestCommand(t, false)
+	out, err := loginAsWithPassword(cmd, up, "alice", &pw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := newTokenOutput(out.AuthenticationResult)
+
+	t.Run("renew with the refresh token", func(t *testing.T) {
+		wrong := "wrong"
+		renewed, err := renewTokens(cmd, up, "alice", &wrong, tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if renewed.IDToken == "" || renewed.RefreshToken != tokens.RefreshToken {
+			t.Errorf("got %+v, want the renewed tokens with the refresh token %q", renewed, tokens.RefreshToken)
+		}
+	})
+
+	t.Run("log in again without the refresh token", func(t *testing.T) {
+		tokens := tokens
+		tokens.RefreshToken = ""
+		renewed, err := renewTokens(cmd, up, "alice", &pw, tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if renewed.IDToken == "" || renewed.RefreshToken == "" {
+			t.Errorf("got %+v, want the tokens of a new login", renewed)
+		}
+	})
+
+	t.Run("renew every 80% of the lifetime", func(t *testing.T) {
+		if got := renewIn(tokenOutput{ExpiresIn: 100}); got.Seconds() != 80 {
+			t.Errorf("got %v, want 80s", got)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/spf13/cobra"
+)
+
+var (
+	filterExpression string
+	attributes       string
+	withGroups       bool
+	withMFA          bool
+	format           string
+)
+
+var exportUsersCmd = &cobra.Command{
+	Use:   "export-users [USER_POOL_ID_OR_NAME]",
+	Short: "export users in the user pool",
+	Long:  `export users in the user pool as JSONL or CSV that can be applied with apply-users. The MFA preferences exported with --with-mfa are for reference and are not applied by apply-users.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		idOrName := args[0]
+		if format != "jsonl" && format != "csv" {
+			return fmt.Errorf("invalid format: %s", format)
+		}
+		if withMFA && format == "csv" {
+			return errors.New("--with-mfa is not supported with CSV format")
+		}
+		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint))
+		if err != nil {
+			return err
+		}
+		lopts := []userpool.ListUsersOptionFunc{}
+		if filterExpression != "" {
+			lopts = append(lopts, userpool.WithFilter(filterExpression))
+		}
+		if attributes != "" {
+			lopts = append(lopts, userpool.WithAttributesToGet(strings.Split(attributes, ",")...))
+		}
+		eopts := []userpool.ExportUserOptionFunc{}
+		if withGroups {
+			eopts = append(eopts, userpool.WithGroups())
+		}
+		if withMFA {
+			eopts = append(eopts, userpool.WithMFA())
+		}
+
+		current, err := up.ListUsers(ctx, lopts...)
+		if err != nil {
+			return err
+		}
+		var users []userpool.User
+		for _, u := range current {
+			user, err := up.ExportUser(ctx, u, eopts...)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", aws.ToString(u.Username), err)
+			}
+			users = append(users, user)
+		}
+
+		if format == "csv" {
+			return writeUsersAsCSV(cmd.OutOrStdout(), users, withGroups)
+		}
+		return writeUsersAsJSONL(cmd.OutOrStdout(), users)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportUsersCmd)
+	exportUsersCmd.Flags().StringVar(&filterExpression, "filter-expression", "", `filter expression of ListUsers (e.g. 'email ^= "user"')`)
+	exportUsersCmd.Flags().StringVar(&attributes, "attributes", "", "comma-separated attribute names to export")
+	exportUsersCmd.Flags().BoolVar(&withGroups, "with-groups", false, "export groups of users")
+	exportUsersCmd.Flags().BoolVar(&withMFA, "with-mfa", false, "export MFA preferences of users for reference (JSONL only, not applied by apply-users)")
+	exportUsersCmd.Flags().StringVar(&format, "format", "jsonl", "output format (jsonl|csv)")
+	exportUsersCmd.Flags().StringVarP(&endpoint, "endpoint", "e", "", "set endpoint")
+}
+
+func writeUsersAsJSONL(w io.Writer, users []userpool.User) error {
+	for _, u := range users {
+		b, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, string(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeUsersAsCSV writes users as CSV with a header line that can be used as --columns of apply-users.
+func writeUsersAsCSV(w io.Writer, users []userpool.User, groups bool) error {
+	var names []string
+	for _, u := range users {
+		for name := range u.Attributes {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	header := []string{"username", "enabled"}
+	if groups {
+		header = append(header, "groups")
+	}
+	header = append(header, names...)
+	if _, err := fmt.Fprintln(w, strings.Join(header, ",")); err != nil {
+		return err
+	}
+	for _, u := range users {
+		fields := []string{u.Username, ""}
+		if u.Enabled != nil {
+			fields[1] = strconv.FormatBool(*u.Enabled)
+		}
+		if groups {
+			fields = append(fields, strings.Join(u.Groups, "|"))
+		}
+		for _, name := range names {
+			v, ok := u.Attributes[name]
+			if !ok {
+				fields = append(fields, "")
+				continue
+			}
+			fields = append(fields, fmt.Sprintf("%v", v))
+		}
+		for _, f := range fields {
+			if strings.Contains(f, ",") {
+				return fmt.Errorf("user %s: CSV does not support values containing comma: %s", u.Username, f)
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(fields, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
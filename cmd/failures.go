@@ -0,0 +1,127 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"cmp"
+	"errors"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/smithy-go"
+)
+
+// unknownErrorCode is the error code of the errors not returned by the Cognito API.
+const unknownErrorCode = "Unknown"
+
+// applyFailure is a user in the users file that failed to be applied.
+type applyFailure struct {
+	line      int
+	username  string
+	operation string
+	code      string
+	err       error
+	raw       string
+}
+
+// failureReport collects the failures of apply-users to continue on errors.
+type failureReport struct {
+	mu       sync.Mutex
+	header   []string
+	failures []applyFailure
+}
+
+func (r *failureReport) add(l int, username, raw string, err error) {
+	operation, code := errorDetail(err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, applyFailure{
+		line:      l,
+		username:  username,
+		operation: operation,
+		code:      code,
+		err:       err,
+		raw:       raw,
+	})
+	slog.Error("failed to apply user", slog.Int("line", l), slog.String("username", username), slog.String("operation", operation), slog.String("code", code), slog.String("error", err.Error()))
+}
+
+func (r *failureReport) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.failures)
+}
+
+// summarize logs the number of the failures by error code.
+func (r *failureReport) summarize() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := map[string]int{}
+	for _, f := range r.failures {
+		counts[f.code]++
+	}
+	for _, code := range slices.Sorted(maps.Keys(counts)) {
+		slog.Error("failures", slog.String("code", code), slog.Int("count", counts[code]))
+	}
+}
+
+// write writes the lines of the failed users to p in the format of the users file, with the CSV header lines if any.
+func (r *failureReport) write(p string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	failures := slices.SortedFunc(slices.Values(r.failures), func(a, b applyFailure) int {
+		return cmp.Compare(a.line, b.line)
+	})
+	var b strings.Builder
+	for _, h := range r.header {
+		b.WriteString(h + "\n")
+	}
+	for _, f := range failures {
+		b.WriteString(f.raw + "\n")
+	}
+	return os.WriteFile(p, []byte(b.String()), 0600)
+}
+
+// failuresPath returns the default path of the failures file, such as users.failed.jsonl for users.jsonl.
+func failuresPath(p string) string {
+	ext := filepath.Ext(p)
+	return strings.TrimSuffix(p, ext) + ".failed" + ext
+}
+
+// errorDetail returns the API operation and the error code of err.
+func errorDetail(err error) (operation, code string) {
+	code = unknownErrorCode
+	var oe *smithy.OperationError
+	if errors.As(err, &oe) {
+		operation = oe.Operation()
+	}
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		code = ae.ErrorCode()
+	}
+	return operation, code
+}
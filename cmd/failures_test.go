@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/aws/smithy-go"
+)
+
+func TestFailuresPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"users.jsonl", "users.failed.jsonl"},
+		{"path/to/users.csv", "path/to/users.failed.csv"},
+		{"users", "users.failed"},
+		{"users.v2.jsonl", "users.v2.failed.jsonl"},
+	}
+	for _, tt := range tests {
+		if got := failuresPath(tt.in); got != tt.want {
+			t.Errorf("failuresPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestErrorDetail(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantOperation string
+		wantCode      string
+	}{
+		{
+			name: "API error",
+			err: fmt.Errorf("user alice: %w", &smithy.OperationError{
+				ServiceID:     "Cognito Identity Provider",
+				OperationName: "AdminCreateUser",
+				Err:           &types.UsernameExistsException{Message: aws.String("User account already exists")},
+			}),
+			wantOperation: "AdminCreateUser",
+			wantCode:      "UsernameExistsException",
+		},
+		{
+			name:          "API error without operation",
+			err:           &types.InvalidParameterException{Message: aws.String("invalid")},
+			wantOperation: "",
+			wantCode:      "InvalidParameterException",
+		},
+		{
+			name:          "other error",
+			err:           errors.New("line 3: invalid character"),
+			wantOperation: "",
+			wantCode:      unknownErrorCode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation, code := errorDetail(tt.err)
+			if operation != tt.wantOperation {
+				t.Errorf("got operation %q, want %q", operation, tt.wantOperation)
+			}
+			if code != tt.wantCode {
+				t.Errorf("got code %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestFailureReportWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		want   string
+	}{
+		{"JSONL", nil, "{\"username\":\"bob\"}\n{\"username\":\"carol\"}\n"},
+		{"CSV", []string{"# users", "username,email"}, "# users\nusername,email\n{\"username\":\"bob\"}\n{\"username\":\"carol\"}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &failureReport{header: tt.header}
+			// failures are added in the order of completion, and written in the order of lines
+			r.add(5, "carol", `{"username":"carol"}`, errors.New("failed"))
+			r.add(2, "bob", `{"username":"bob"}`, &types.UserNotFoundException{})
+			if got := r.len(); got != 2 {
+				t.Errorf("got %d failures, want 2", got)
+			}
+			r.summarize()
+			p := filepath.Join(t.TempDir(), "users.failed.jsonl")
+			if err := r.write(p); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %q, want %q", b, tt.want)
+			}
+		})
+	}
+}
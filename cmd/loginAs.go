@@ -22,21 +22,28 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
 	"github.com/k1LoW/coglet/userpool"
 	"github.com/spf13/cobra"
 )
 
 var (
-	client   string
-	useCache bool
+	client            string
+	clientSecret      string
+	authFlow          string
+	newPassword       string
+	randomNewPassword bool
+	userAttributes    string
+	totpSecret        string
+	setupMFA          bool
+	useCache          bool
+	output            string
 )
 
 var loginAsCmd = &cobra.Command{
@@ -45,54 +52,20 @@ var loginAsCmd = &cobra.Command{
 	Long:  `login as the user in the user pool.`,
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := cmd.Context()
 		idOrName := args[0]
 		username := args[1]
-		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint))
-		if err != nil {
+		if err := validateOutputFormat(output); err != nil {
 			return err
 		}
-		if password == "" {
-			password = os.Getenv("COGLET_PASSWORD")
-		}
-		key := fmt.Sprintf("%s:%s", up.ID(), username)
-
-		if useCache {
-			out, err := getTokenFromCache(key)
-			if err == nil {
-				b, err := json.Marshal(out)
-				if err != nil {
-					return err
-				}
-				fmt.Println(string(b))
-				return nil
-			}
-		}
-
-		cm, err := parseClientMetadata(clientMetadata)
-		if err != nil {
-			return nil
-		}
-		user := userpool.User{
-			Username:       username,
-			Password:       password,
-			ClientMetadata: cm,
-		}
-		out, err := up.LoginAs(ctx, user, userpool.WithClientIDOrName(client))
+		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint))
 		if err != nil {
 			return err
 		}
-		b, err := json.Marshal(out)
+		out, err := loginAs(cmd, up, username)
 		if err != nil {
 			return err
 		}
-		if useCache {
-			if err := saveTokenAsCache(key, out); err != nil {
-				return err
-			}
-		}
-		fmt.Println(string(b))
-		return nil
+		return writeTokens(cmd.OutOrStdout(), output, out, out.AuthenticationResult)
 	},
 }
 
@@ -100,58 +73,168 @@ func init() {
 	rootCmd.AddCommand(loginAsCmd)
 	loginAsCmd.Flags().StringVarP(&password, "password", "p", "", "password. if not set, use COGLET_PASSWORD env")
 	loginAsCmd.Flags().StringVarP(&client, "client", "c", "", "user pool client id or name")
+	loginAsCmd.Flags().StringVarP(&clientSecret, "client-secret", "", "", "user pool client secret. if not set, use COGLET_CLIENT_SECRET env or get it from the user pool client")
+	loginAsCmd.Flags().StringVarP(&authFlow, "auth-flow", "", "", "auth flow (USER_PASSWORD_AUTH|USER_SRP_AUTH). if not set, select from the explicit auth flows of the user pool client")
+	loginAsCmd.Flags().StringVarP(&newPassword, "new-password", "", "", "new password for the NEW_PASSWORD_REQUIRED challenge. if not set, use COGLET_NEW_PASSWORD env")
+	loginAsCmd.Flags().BoolVarP(&randomNewPassword, "random-new-password", "", false, "set random new password for the NEW_PASSWORD_REQUIRED challenge")
+	loginAsCmd.Flags().StringVarP(&userAttributes, "user-attributes", "", "", "set required attributes for the NEW_PASSWORD_REQUIRED challenge")
+	loginAsCmd.Flags().StringVarP(&totpSecret, "totp-secret", "", "", "base32 encoded TOTP secret for the SOFTWARE_TOKEN_MFA challenge. if not set, use COGLET_TOTP_SECRET env or the stored secret")
+	loginAsCmd.Flags().BoolVarP(&setupMFA, "setup-mfa", "", false, "set up TOTP MFA for the MFA_SETUP challenge and store the secret")
 	loginAsCmd.Flags().StringVarP(&clientMetadata, "client-metadata", "m", "", "set client metadata")
 	loginAsCmd.Flags().StringVarP(&endpoint, "endpoint", "e", "", "set endpoint")
 	loginAsCmd.Flags().BoolVarP(&useCache, "use-cache", "", false, "use cache")
+	loginAsCmd.Flags().StringVarP(&output, "output", "o", "raw", "output format (raw|json|id-token|access-token|refresh-token|header|env|dotenv)")
+	loginAsCmd.Flags().StringVarP(&cacheKeyFile, "cache-key-file", "", "", "key file to encrypt the token cache. if not set, use COGLET_CACHE_KEY_FILE env or COGLET_CACHE_PASSPHRASE env")
 }
 
-type cache struct {
-	ExpiredAt int64 `json:"expired_at"`
-	Auth      *cognito.InitiateAuthOutput
-}
-
-func saveTokenAsCache(key string, out *cognito.InitiateAuthOutput) error {
-	if _, err := os.Stat(statePath()); err != nil {
-		if err := os.MkdirAll(statePath(), 0700); err != nil {
-			return err
+// loginAsOptions returns the options of LoginAs from the flags and the environment variables.
+// It does not modify the flags, so that proxy can call it for multiple users concurrently.
+func loginAsOptions(cmd *cobra.Command, up *userpool.Client, username string) ([]userpool.LoginAsOptionFunc, error) {
+	var err error
+	key := fmt.Sprintf("%s:%s", up.ID(), username)
+	secret := flagOrEnv(totpSecret, "COGLET_TOTP_SECRET")
+	if secret == "" {
+		secret, err = loadTOTPSecret(key)
+		if err != nil {
+			return nil, err
 		}
 	}
-	c := cache{
-		ExpiredAt: int64(out.AuthenticationResult.ExpiresIn) + time.Now().Unix() - 60, // 1 min before
-		Auth:      out,
+
+	opts := []userpool.LoginAsOptionFunc{
+		userpool.WithClientIDOrName(client),
+		userpool.WithClientSecret(flagOrEnv(clientSecret, "COGLET_CLIENT_SECRET")),
+		userpool.WithTOTPSecret(secret),
 	}
-	b, err := json.Marshal(c)
+	if setupMFA {
+		opts = append(opts, userpool.WithSetupTOTP(func(secret string) error {
+			if err := saveTOTPSecret(key, secret); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "the TOTP secret of %s has been set up: %s\n", username, secret)
+			return nil
+		}))
+	}
+	if authFlow != "" {
+		opts = append(opts, userpool.WithAuthFlow(types.AuthFlowType(strings.ToUpper(authFlow))))
+	}
+	// each command has its own default of --use-cache, so read the flag of the running command.
+	// commands without --use-cache (mfa enroll) do not use the token cache.
+	if cmd.Flags().Lookup("use-cache") == nil {
+		return opts, nil
+	}
+	useCache, err := cmd.Flags().GetBool("use-cache")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if useCache {
+		tc, err := newTokenCache(cmd)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, userpool.WithTokenCache(tc))
 	}
+	return opts, nil
+}
 
-	return os.WriteFile(cachePath(key), b, 0600)
+// loginAs logs in as the user with the flags and the environment variables, responding to the challenges that can be answered.
+func loginAs(cmd *cobra.Command, up *userpool.Client, username string) (*cognito.InitiateAuthOutput, error) {
+	if password == "" {
+		password = os.Getenv("COGLET_PASSWORD")
+	}
+	return loginAsWithPassword(cmd, up, username, &password)
 }
 
-func getTokenFromCache(key string) (*cognito.InitiateAuthOutput, error) {
-	if _, err := os.Stat(statePath()); err != nil {
+// loginAsWithPassword logs in as the user with the password.
+// The password is updated when it is changed in the NEW_PASSWORD_REQUIRED challenge.
+func loginAsWithPassword(cmd *cobra.Command, up *userpool.Client, username string, password *string) (*cognito.InitiateAuthOutput, error) {
+	ctx := cmd.Context()
+	opts, err := loginAsOptions(cmd, up, username)
+	if err != nil {
 		return nil, err
 	}
-	b, err := os.ReadFile(cachePath(key))
+	cm, err := parseClientMetadata(clientMetadata)
 	if err != nil {
 		return nil, err
 	}
-	c := cache{}
-	if err := json.Unmarshal(b, &c); err != nil {
+	attrs, err := parseClientMetadata(userAttributes)
+	if err != nil {
 		return nil, err
 	}
-	if c.ExpiredAt < time.Now().Unix() {
-		if err := os.Remove(cachePath(key)); err != nil {
+	user := userpool.User{
+		Username:       username,
+		Password:       *password,
+		Attributes:     map[string]any{},
+		ClientMetadata: cm,
+	}
+	for k, v := range attrs {
+		user.Attributes[k] = v
+	}
+	out, err := up.LoginAs(ctx, user, opts...)
+	if err != nil {
+		return nil, err
+	}
+	np := flagOrEnv(newPassword, "COGLET_NEW_PASSWORD")
+	if out.ChallengeName == types.ChallengeNameTypeNewPasswordRequired && (np != "" || randomNewPassword) {
+		if np == "" {
+			np, err = up.GeneratePassword(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		out, err = up.LoginAs(ctx, user, append(opts, userpool.WithNewPassword(np))...)
+		if err != nil {
 			return nil, err
 		}
-		return nil, fmt.Errorf("token expired")
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "the password of %s has been changed to: %s\n", username, np)
+		// log in with the changed password from now on
+		*password = np
+	}
+	if out.AuthenticationResult == nil {
+		switch out.ChallengeName {
+		case types.ChallengeNameTypeNewPasswordRequired:
+			return nil, fmt.Errorf("%s challenge is required. set --new-password or --random-new-password", out.ChallengeName)
+		case types.ChallengeNameTypeSoftwareTokenMfa, types.ChallengeNameTypeSelectMfaType:
+			return nil, fmt.Errorf("%s challenge is required. set --totp-secret", out.ChallengeName)
+		case types.ChallengeNameTypeMfaSetup:
+			return nil, fmt.Errorf("%s challenge is required. set --setup-mfa", out.ChallengeName)
+		}
+		return nil, fmt.Errorf("%s challenge is required", out.ChallengeName)
+	}
+	return out, nil
+}
+
+// loadTOTPSecret returns the stored TOTP secret. It returns an empty string if no secret is stored.
+func loadTOTPSecret(key string) (string, error) {
+	secrets, err := newTOTPSecrets()
+	if err != nil {
+		return "", err
 	}
-	return c.Auth, nil
+	return secrets.Get(totpSecretID(key))
 }
 
-func cachePath(key string) string {
+func saveTOTPSecret(key, secret string) error {
+	secrets, err := newTOTPSecrets()
+	if err != nil {
+		return err
+	}
+	return secrets.Set(totpSecretID(key), secret)
+}
+
+func totpSecretID(key string) string {
 	r := strings.NewReplacer(":", "_", "/", "_")
-	return filepath.Join(statePath(), fmt.Sprintf("%s.json", r.Replace(key)))
+	return r.Replace(key)
+}
+
+func totpDir() string {
+	return filepath.Join(statePath(), "totp")
+}
+
+// flagOrEnv returns the value of the flag, or the value of the environment variable if the flag is not set.
+func flagOrEnv(v, env string) string {
+	if v == "" {
+		return os.Getenv(env)
+	}
+	return v
 }
 
 func statePath() string {
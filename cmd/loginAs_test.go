@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/coglet/userpool/userpooltest"
+	"github.com/spf13/cobra"
+)
+
+func TestUseCacheDefault(t *testing.T) {
+	tests := []struct {
+		cmd  *cobra.Command
+		want bool
+	}{
+		{loginAsCmd, false},
+		{execCmd, true},
+		{proxyCmd, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cmd.Name(), func(t *testing.T) {
+			got, err := tt.cmd.Flags().GetBool("use-cache")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginAsWithPasswordNewPasswordRequired(t *testing.T) {
+	tests := []struct {
+		name         string
+		newPassword  string
+		envPassword  string
+		random       bool
+		wantPassword string
+		wantErr      string
+	}{
+		{name: "new password", newPassword: "NewPassw0rd!", wantPassword: "NewPassw0rd!"},
+		{name: "new password from env", envPassword: "EnvPassw0rd!", wantPassword: "EnvPassw0rd!"},
+		{name: "random new password", random: true},
+		{name: "no new password", wantErr: "set --new-password or --random-new-password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, id, up, clientID := newTestUserPool(t)
+			if err := up.ApplyUser(context.Background(), userpool.User{Username: "alice"}, userpool.WithPassword("TempPassw0rd!")); err != nil {
+				t.Fatal(err)
+			}
+			setLoginAsFlags(t, clientID)
+			newPassword = tt.newPassword
+			randomNewPassword = tt.random
+			t.Setenv("COGLET_NEW_PASSWORD", tt.envPassword)
+			cmd, _, stderr := newTestCommand(t, false)
+
+			pw := "TempPassw0rd!"
+			out, err := loginAsWithPassword(cmd, up, "alice", &pw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.AuthenticationResult == nil {
+				t.Fatalf("got %+v, want tokens", out)
+			}
+			if tt.wantPassword != "" && pw != tt.wantPassword {
+				t.Errorf("password: got %q, want %q", pw, tt.wantPassword)
+			}
+			if pw == "TempPassw0rd!" {
+				t.Error("password is not updated")
+			}
+			got, _ := api.GetUser(id, "alice")
+			if got.Password != pw || got.Status != types.UserStatusTypeConfirmed {
+				t.Errorf("got password %q and status %s, want %q and %s", got.Password, got.Status, pw, types.UserStatusTypeConfirmed)
+			}
+			if !strings.Contains(stderr.String(), pw) {
+				t.Errorf("stderr: got %q, want the new password", stderr.String())
+			}
+			// log in again with the changed password
+			if _, err := loginAsWithPassword(cmd, up, "alice", &pw); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLoginAsWithPasswordTOTP(t *testing.T) {
+	ctx := context.Background()
+	api, id, up, clientID := newTestUserPool(t)
+	if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.SetMFAConfiguration(id, types.UserPoolMfaTypeOn); err != nil {
+		t.Fatal(err)
+	}
+	setLoginAsFlags(t, clientID)
+	pw := "Passw0rd!"
+
+	t.Run("MFA_SETUP is required", func(t *testing.T) {
+		cmd, _, _ := newTestCommand(t, false)
+		if _, err := loginAsWithPassword(cmd, up, "alice", &pw); err == nil || !strings.Contains(err.Error(), "set --setup-mfa") {
+			t.Errorf("got %v, want MFA_SETUP error", err)
+		}
+	})
+
+	t.Run("set up and store the secret", func(t *testing.T) {
+		setupMFA = true
+		t.Cleanup(func() { setupMFA = false })
+		cmd, _, stderr := newTestCommand(t, false)
+		if _, err := loginAsWithPassword(cmd, up, "alice", &pw); err != nil {
+			t.Fatal(err)
+		}
+		secret, err := loadTOTPSecret(fmt.Sprintf("%s:%s", up.ID(), "alice"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret == "" || !strings.Contains(stderr.String(), secret) {
+			t.Errorf("got stored secret %q and stderr %q", secret, stderr.String())
+		}
+	})
+
+	t.Run("answer SOFTWARE_TOKEN_MFA with the stored secret", func(t *testing.T) {
+		cmd, _, _ := newTestCommand(t, false)
+		if _, err := loginAsWithPassword(cmd, up, "alice", &pw); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("--totp-secret takes precedence over the stored secret", func(t *testing.T) {
+		totpSecret = "JBSWY3DPEHPK3PXP"
+		t.Cleanup(func() { totpSecret = "" })
+		cmd, _, _ := newTestCommand(t, false)
+		if _, err := loginAsWithPassword(cmd, up, "alice", &pw); err == nil {
+			t.Error("want error with a wrong secret")
+		}
+	})
+}
+
+// newTestUserPool returns a fake user pool with an app client without a client secret.
+func newTestUserPool(t *testing.T) (*userpooltest.API, string, *userpool.Client, string) {
+	t.Helper()
+	api := userpooltest.NewAPI()
+	id := api.CreateUserPool("test")
+	clientID, err := api.CreateUserPoolClient(id, "app", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, err := userpool.New(id, userpool.WithAPI(api))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api, id, up, clientID
+}
+
+// setLoginAsFlags resets the flags of login-as for the client and restores them after the test.
+func setLoginAsFlags(t *testing.T, clientID string) {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	for _, env := range []string{"COGLET_PASSWORD", "COGLET_NEW_PASSWORD", "COGLET_TOTP_SECRET", "COGLET_CLIENT_SECRET", "COGLET_CACHE_KEY_FILE", "COGLET_CACHE_PASSPHRASE"} {
+		t.Setenv(env, "")
+	}
+	strs := []*string{&password, &client, &clientSecret, &authFlow, &newPassword, &userAttributes, &totpSecret, &clientMetadata, &output, &cacheKeyFile}
+	bools := []*bool{&randomNewPassword, &setupMFA}
+	savedStrs := make([]string, len(strs))
+	for i, p := range strs {
+		savedStrs[i] = *p
+		*p = ""
+	}
+	savedBools := make([]bool, len(bools))
+	for i, p := range bools {
+		savedBools[i] = *p
+		*p = false
+	}
+	t.Cleanup(func() {
+		for i, p := range strs {
+			*p = savedStrs[i]
+		}
+		for i, p := range bools {
+			*p = savedBools[i]
+		}
+	})
+	client = clientID
+	output = "raw"
+}
+
+// newTestCommand returns a command with the --use-cache flag and buffers of its stdout and stderr.
+func newTestCommand(t *testing.T, useCache bool) (*cobra.Command, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("use-cache", useCache, "")
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetContext(context.Background())
+	return cmd, stdout, stderr
+}
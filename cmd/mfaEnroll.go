@@ -0,0 +1,115 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/k1LoW/coglet/internal/totp"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/mdp/qrterminal/v3"
+	"github.com/spf13/cobra"
+)
+
+var (
+	showSecret bool
+	showQR     bool
+)
+
+var mfaEnrollCmd = &cobra.Command{
+	Use:   "enroll [USER_POOL_ID_OR_NAME] [USERNAME]",
+	Short: "enroll TOTP MFA for the user",
+	Long:  `enroll TOTP MFA for the user by logging in as the user and verifying a new software token. The secret is stored for login-as.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		idOrName := args[0]
+		username := args[1]
+		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint))
+		if err != nil {
+			return err
+		}
+		return enrollTOTP(cmd, up, username)
+	},
+}
+
+// enrollTOTP enrolls TOTP MFA for the user and stores the secret.
+// The secret is printed only with --show-secret, and the QR code that encodes it only with --qr.
+func enrollTOTP(cmd *cobra.Command, up *userpool.Client, username string) error {
+	ctx := cmd.Context()
+	opts, err := loginAsOptions(cmd, up, username)
+	if err != nil {
+		return err
+	}
+	user := userpool.User{
+		Username: username,
+		Password: flagOrEnv(password, "COGLET_PASSWORD"),
+	}
+	secret, err := up.EnrollSoftwareToken(ctx, user, opts...)
+	if err != nil {
+		return err
+	}
+	if err := saveTOTPSecret(fmt.Sprintf("%s:%s", up.ID(), username), secret); err != nil {
+		return err
+	}
+	if !showQR && !showSecret {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "TOTP MFA of %s has been enrolled. the secret is stored for login-as\n", username)
+		return nil
+	}
+	name, err := up.Name(ctx)
+	if err != nil {
+		return err
+	}
+	uri := totp.URI(name, username, secret)
+	if showQR {
+		qrterminal.GenerateHalfBlock(uri, qrterminal.L, cmd.ErrOrStderr())
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "TOTP MFA of %s has been enrolled. scan the QR code with an authenticator app\n", username)
+	}
+	if !showSecret {
+		return nil
+	}
+	b, err := json.Marshal(struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}{
+		Secret: secret,
+		URI:    uri,
+	})
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), string(b))
+	return nil
+}
+
+func init() {
+	mfaCmd.AddCommand(mfaEnrollCmd)
+	mfaEnrollCmd.Flags().StringVarP(&password, "password", "p", "", "password. if not set, use COGLET_PASSWORD env")
+	mfaEnrollCmd.Flags().StringVarP(&client, "client", "c", "", "user pool client id or name")
+	mfaEnrollCmd.Flags().StringVarP(&clientSecret, "client-secret", "", "", "user pool client secret. if not set, use COGLET_CLIENT_SECRET env or get it from the user pool client")
+	mfaEnrollCmd.Flags().StringVarP(&authFlow, "auth-flow", "", "", "auth flow (USER_PASSWORD_AUTH|USER_SRP_AUTH). if not set, select from the explicit auth flows of the user pool client")
+	mfaEnrollCmd.Flags().StringVarP(&totpSecret, "totp-secret", "", "", "base32 encoded TOTP secret of the current software token. if not set, use COGLET_TOTP_SECRET env or the stored secret")
+	mfaEnrollCmd.Flags().BoolVarP(&showSecret, "show-secret", "", false, "print the TOTP secret and the otpauth:// URI to stdout as JSON")
+	mfaEnrollCmd.Flags().BoolVarP(&showQR, "qr", "", false, "print the QR code of the otpauth:// URI to stderr. the QR code contains the TOTP secret")
+	mfaEnrollCmd.Flags().StringVarP(&cacheKeyFile, "cache-key-file", "", "", "key file to encrypt the stored TOTP secret. if not set, use COGLET_CACHE_KEY_FILE env or COGLET_CACHE_PASSPHRASE env")
+	mfaEnrollCmd.Flags().StringVarP(&endpoint, "endpoint", "e", "", "set endpoint")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/k1LoW/coglet/userpool"
+)
+
+func TestEnrollTOTP(t *testing.T) {
+	tests := []struct {
+		name       string
+		showSecret bool
+		showQR     bool
+	}{
+		{"default", false, false},
+		{"--show-secret", true, false},
+		{"--qr", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, up, clientID := newTestUserPool(t)
+			if err := up.ApplyUser(context.Background(), userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+				t.Fatal(err)
+			}
+			setLoginAsFlags(t, clientID)
+			password = "Passw0rd!"
+			showSecret, showQR = tt.showSecret, tt.showQR
+			t.Cleanup(func() { showSecret, showQR = false, false })
+			cmd, stdout, stderr := newTestCommand(t, false)
+
+			if err := enrollTOTP(cmd, up, "alice"); err != nil {
+				t.Fatal(err)
+			}
+			secret, err := loadTOTPSecret(fmt.Sprintf("%s:%s", up.ID(), "alice"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if secret == "" {
+				t.Fatal("the secret is not stored")
+			}
+			if tt.showSecret {
+				var got struct {
+					Secret string `json:"secret"`
+					URI    string `json:"uri"`
+				}
+				if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Secret != secret || !strings.Contains(got.URI, secret) {
+					t.Errorf("got %+v, want secret %s", got, secret)
+				}
+			} else if stdout.Len() > 0 {
+				t.Errorf("stdout: got %q, want nothing", stdout.String())
+			}
+			if strings.Contains(stderr.String(), secret) {
+				t.Errorf("stderr contains the secret: %q", stderr.String())
+			}
+			// the QR code is drawn with half blocks
+			if got := strings.Contains(stderr.String(), "▀"); got != tt.showQR {
+				t.Errorf("QR code printed: got %v, want %v", got, tt.showQR)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/spf13/cobra"
+)
+
+var mfaStatusCmd = &cobra.Command{
+	Use:   "status [USER_POOL_ID_OR_NAME] [USERNAME]",
+	Short: "show MFA status of the user",
+	Long:  `show MFA status of the user.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		idOrName := args[0]
+		username := args[1]
+		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint))
+		if err != nil {
+			return err
+		}
+		mfa, err := up.UserMFA(ctx, username)
+		if err != nil {
+			return err
+		}
+		if mfa == nil {
+			mfa = &userpool.MFA{}
+		}
+		b, err := json.Marshal(struct {
+			Username string `json:"username"`
+			*userpool.MFA
+		}{
+			Username: username,
+			MFA:      mfa,
+		})
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), string(b))
+		return nil
+	},
+}
+
+func init() {
+	mfaCmd.AddCommand(mfaStatusCmd)
+	mfaStatusCmd.Flags().StringVarP(&endpoint, "endpoint", "e", "", "set endpoint")
+}
@@ -0,0 +1,150 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/internal/jwt"
+)
+
+// tokenOutputVersion is the version of the JSON schema printed by --output json.
+// It is incremented only on incompatible changes.
+const tokenOutputVersion = 1
+
+var outputFormats = []string{"raw", "json", "id-token", "access-token", "refresh-token", "header", "env", "dotenv"}
+
+// tokenOutput is the stable JSON schema of the tokens, independent of the AWS SDK.
+type tokenOutput struct {
+	Version      int       `json:"version"`
+	IDToken      string    `json:"id_token"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int64     `json:"expires_in"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+func newTokenOutput(r *types.AuthenticationResultType) tokenOutput {
+	expiresAt, ok := tokenExpiry(aws.ToString(r.AccessToken))
+	if !ok {
+		expiresAt = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return tokenOutput{
+		Version:      tokenOutputVersion,
+		IDToken:      aws.ToString(r.IdToken),
+		AccessToken:  aws.ToString(r.AccessToken),
+		RefreshToken: aws.ToString(r.RefreshToken),
+		TokenType:    aws.ToString(r.TokenType),
+		ExpiresIn:    max(int64(time.Until(expiresAt).Seconds()), 0),
+		ExpiresAt:    expiresAt.UTC().Truncate(time.Second),
+	}
+}
+
+// env returns the tokens as environment variables.
+func (o tokenOutput) env() [][2]string {
+	env := [][2]string{
+		{"COGLET_ID_TOKEN", o.IDToken},
+		{"COGLET_ACCESS_TOKEN", o.AccessToken},
+	}
+	if o.RefreshToken != "" {
+		env = append(env, [2]string{"COGLET_REFRESH_TOKEN", o.RefreshToken})
+	}
+	return append(env, [2]string{"COGLET_TOKEN_EXPIRES_AT", o.ExpiresAt.Format(time.RFC3339)})
+}
+
+func validateOutputFormat(format string) error {
+	if !slices.Contains(outputFormats, format) {
+		return fmt.Errorf("invalid output format: %s. must be one of %s", format, strings.Join(outputFormats, "|"))
+	}
+	return nil
+}
+
+// writeTokens writes the tokens in the output format.
+// raw writes v as is, which is the output of the AWS SDK.
+func writeTokens(w io.Writer, format string, v any, r *types.AuthenticationResultType) error {
+	o := newTokenOutput(r)
+	switch format {
+	case "raw":
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case "json":
+		b, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case "id-token":
+		_, err := fmt.Fprintln(w, o.IDToken)
+		return err
+	case "access-token":
+		_, err := fmt.Fprintln(w, o.AccessToken)
+		return err
+	case "refresh-token":
+		if o.RefreshToken == "" {
+			return errors.New("no refresh token")
+		}
+		_, err := fmt.Fprintln(w, o.RefreshToken)
+		return err
+	case "header":
+		_, err := fmt.Fprintf(w, "Authorization: Bearer %s\n", o.AccessToken)
+		return err
+	case "env":
+		for _, kv := range o.env() {
+			if _, err := fmt.Fprintf(w, "export %s='%s'\n", kv[0], kv[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "dotenv":
+		for _, kv := range o.env() {
+			if _, err := fmt.Fprintf(w, "%s=%s\n", kv[0], kv[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return validateOutputFormat(format)
+	}
+}
+
+// tokenExpiry returns the exp claim of the JWT without verifying it.
+func tokenExpiry(token string) (time.Time, bool) {
+	t, err := jwt.Parse(token)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.Time("exp")
+}
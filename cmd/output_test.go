@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+// unsignedToken returns a JWT with the exp claim. It is not signed.
+func unsignedToken(exp time.Time) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"RS256"}`)) + "." + enc(fmt.Appendf(nil, `{"exp":%d}`, exp.Unix())) + "." + enc([]byte("sig"))
+}
+
+func TestWriteTokens(t *testing.T) {
+	exp := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	access := unsignedToken(exp)
+	r := &types.AuthenticationResultType{
+		IdToken:      aws.String("id"),
+		AccessToken:  aws.String(access),
+		RefreshToken: aws.String("refresh"),
+		TokenType:    aws.String("Bearer"),
+		ExpiresIn:    3600,
+	}
+	out := &cognito.InitiateAuthOutput{AuthenticationResult: r}
+	expiresAt := exp.Format(time.RFC3339)
+
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"id-token", "id\n", false},
+		{"access-token", access + "\n", false},
+		{"refresh-token", "refresh\n", false},
+		{"header", "Authorization: Bearer " + access + "\n", false},
+		{"env", "export COGLET_ID_TOKEN='id'\nexport COGLET_ACCESS_TOKEN='" + access + "'\nexport COGLET_REFRESH_TOKEN='refresh'\nexport COGLET_TOKEN_EXPIRES_AT='" + expiresAt + "'\n", false},
+		{"dotenv", "COGLET_ID_TOKEN=id\nCOGLET_ACCESS_TOKEN=" + access + "\nCOGLET_REFRESH_TOKEN=refresh\nCOGLET_TOKEN_EXPIRES_AT=" + expiresAt + "\n", false},
+		{"yaml", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			err := writeTokens(buf, tt.format, out, r)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("raw", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := writeTokens(buf, "raw", out, r); err != nil {
+			t.Fatal(err)
+		}
+		var got cognito.InitiateAuthOutput
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if aws.ToString(got.AuthenticationResult.IdToken) != "id" || got.AuthenticationResult.ExpiresIn != 3600 {
+			t.Errorf("got %+v", got.AuthenticationResult)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := writeTokens(buf, "json", out, r); err != nil {
+			t.Fatal(err)
+		}
+		// the keys of the version 1 schema must not change
+		var got map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]any{
+			"version":       float64(1),
+			"id_token":      "id",
+			"access_token":  access,
+			"refresh_token": "refresh",
+			"token_type":    "Bearer",
+			"expires_at":    expiresAt,
+		}
+		expiresIn, ok := got["expires_in"].(float64)
+		if !ok || expiresIn <= 3500 || expiresIn > 3600 {
+			t.Errorf("expires_in: got %v", got["expires_in"])
+		}
+		delete(got, "expires_in")
+		if len(got) != len(want) {
+			t.Errorf("got keys %v, want %v", got, want)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: got %v, want %v", k, got[k], v)
+			}
+		}
+	})
+
+	t.Run("without the refresh token", func(t *testing.T) {
+		r := *r
+		r.RefreshToken = nil
+		if err := writeTokens(new(bytes.Buffer), "refresh-token", out, &r); err == nil {
+			t.Error("want error")
+		}
+		buf := new(bytes.Buffer)
+		if err := writeTokens(buf, "json", out, &r); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "refresh_token") {
+			t.Errorf("got %s, want no refresh_token", buf.String())
+		}
+		buf.Reset()
+		if err := writeTokens(buf, "dotenv", out, &r); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "COGLET_REFRESH_TOKEN") {
+			t.Errorf("got %s, want no COGLET_REFRESH_TOKEN", buf.String())
+		}
+	})
+
+	t.Run("expiry from ExpiresIn", func(t *testing.T) {
+		r := *r
+		r.AccessToken = aws.String("opaque")
+		o := newTokenOutput(&r)
+		if d := time.Until(o.ExpiresAt); d <= 59*time.Minute || d > time.Hour {
+			t.Errorf("got expires_at %v", o.ExpiresAt)
+		}
+	})
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, f := range outputFormats {
+		if err := validateOutputFormat(f); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := validateOutputFormat("yaml"); err == nil {
+		t.Error("want error")
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/k1LoW/coglet/userpool"
+)
+
+var errChangesPending = errors.New("changes pending")
+
+type planSummary struct {
+	create int
+	update int
+	delete int
+	noop   int
+}
+
+func (s *planSummary) add(p *userpool.UserPlan) {
+	switch p.Action {
+	case userpool.PlanActionCreate:
+		s.create++
+	case userpool.PlanActionUpdate:
+		s.update++
+	default:
+		s.noop++
+	}
+}
+
+func (s *planSummary) pending() bool {
+	return s.create+s.update+s.delete > 0
+}
+
+func (s *planSummary) print(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete, %d unchanged.\n", s.create, s.update, s.delete, s.noop)
+}
+
+func printUserPlan(w io.Writer, p *userpool.UserPlan) {
+	switch p.Action {
+	case userpool.PlanActionCreate:
+		_, _ = fmt.Fprintf(w, "  + %s (create)\n", p.Username)
+	case userpool.PlanActionUpdate:
+		_, _ = fmt.Fprintf(w, "  ~ %s (update)\n", p.Username)
+	default:
+		_, _ = fmt.Fprintf(w, "    %s (no-op)\n", p.Username)
+	}
+	for _, c := range p.Changes {
+		if c.Old == nil {
+			_, _ = fmt.Fprintf(w, "      + %s = %q\n", c.Name, c.New)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "      ~ %s = %q -> %q\n", c.Name, *c.Old, c.New)
+	}
+}
+
+func printPrunePlan(w io.Writer, s *planSummary, username, action string) {
+	if action == pruneActionDelete {
+		s.delete++
+		_, _ = fmt.Fprintf(w, "  - %s (delete)\n", username)
+		return
+	}
+	s.update++
+	_, _ = fmt.Fprintf(w, "  ~ %s (update)\n", username)
+	_, _ = fmt.Fprintf(w, "      ~ enabled = %q -> %q\n", "true", "false")
+}
@@ -0,0 +1,299 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listen        string
+	target        string
+	userHeader    string
+	tokenType     string
+	usersFile     string
+	proxyUseCache bool
+)
+
+// loginFailureTTL is how long a failed login is reused so that requests of the user do not hammer the user pool.
+const loginFailureTTL = 10 * time.Second
+
+// errUnknownUser is returned for the users that the proxy is not allowed to switch to.
+var errUnknownUser = errors.New("unknown user")
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy [USER_POOL_ID_OR_NAME] [USERNAME]",
+	Short: "run a reverse proxy that adds the bearer token of the user",
+	Long:  `run a reverse proxy that forwards requests to the target with the bearer token of the user.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		cmd.SetContext(ctx)
+		idOrName := args[0]
+		username := args[1]
+		if tokenType != "id" && tokenType != "access" {
+			return fmt.Errorf("invalid token type: %s", tokenType)
+		}
+		u, err := url.Parse(target)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid target: %s", target)
+		}
+		up, err := userpool.New(idOrName, userpool.WithEndpoint(endpoint))
+		if err != nil {
+			return err
+		}
+		passwords := map[string]string{}
+		if usersFile != "" {
+			passwords, err = readPasswords(usersFile)
+			if err != nil {
+				return err
+			}
+		}
+		if password == "" {
+			password = os.Getenv("COGLET_PASSWORD")
+		}
+		if password != "" {
+			passwords[username] = password
+		}
+		// the tokens of different users are renewed concurrently
+		var pwMu sync.Mutex
+		// users without a password can be logged in only with the cached tokens
+		anyUser := cmd.Flags().Changed("use-cache") && proxyUseCache
+		tp := &tokenProvider{
+			allow: func(u string) bool {
+				if anyUser || u == username {
+					return true
+				}
+				pwMu.Lock()
+				defer pwMu.Unlock()
+				_, ok := passwords[u]
+				return ok
+			},
+			renew: func(ctx context.Context, username string, current *tokenOutput) (tokenOutput, error) {
+				pwMu.Lock()
+				pw := passwords[username]
+				pwMu.Unlock()
+				var t tokenOutput
+				if current == nil {
+					out, err := loginAsWithPassword(cmd, up, username, &pw)
+					if err != nil {
+						return tokenOutput{}, err
+					}
+					t = newTokenOutput(out.AuthenticationResult)
+				} else {
+					renewed, err := renewTokens(cmd, up, username, &pw, *current)
+					if err != nil {
+						return tokenOutput{}, err
+					}
+					t = renewed
+				}
+				pwMu.Lock()
+				passwords[username] = pw
+				pwMu.Unlock()
+				return t, nil
+			},
+		}
+		// log in before accepting requests to fail fast
+		if _, err := tp.token(ctx, username); err != nil {
+			return err
+		}
+
+		server := &http.Server{
+			Addr:              listen,
+			Handler:           newProxyHandler(u, username, userHeader, tokenType, tp.token),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			<-ctx.Done()
+			sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
+			_ = server.Shutdown(sctx)
+		}()
+		slog.Info("proxy started", slog.String("listen", listen), slog.String("target", u.String()), slog.String("username", username))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		slog.Info("proxy stopped")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+	proxyCmd.Flags().StringVarP(&listen, "listen", "l", "localhost:8080", "address to listen on")
+	proxyCmd.Flags().StringVarP(&target, "target", "t", "", "target URL to forward requests to")
+	proxyCmd.Flags().StringVarP(&userHeader, "user-header", "", "X-Coglet-User", "request header to switch the user per request")
+	proxyCmd.Flags().StringVarP(&tokenType, "token", "", "access", "token to add to requests (id|access)")
+	proxyCmd.Flags().StringVarP(&usersFile, "users", "", "", "JSONL file of the usernames and passwords of the users to switch to")
+	proxyCmd.Flags().StringVarP(&password, "password", "p", "", "password. if not set, use COGLET_PASSWORD env")
+	proxyCmd.Flags().StringVarP(&client, "client", "c", "", "user pool client id or name")
+	proxyCmd.Flags().StringVarP(&clientSecret, "client-secret", "", "", "user pool client secret. if not set, use COGLET_CLIENT_SECRET env or get it from the user pool client")
+	proxyCmd.Flags().StringVarP(&authFlow, "auth-flow", "", "", "auth flow (USER_PASSWORD_AUTH|USER_SRP_AUTH). if not set, select from the explicit auth flows of the user pool client")
+	proxyCmd.Flags().StringVarP(&clientMetadata, "client-metadata", "m", "", "set client metadata")
+	proxyCmd.Flags().StringVarP(&endpoint, "endpoint", "e", "", "set endpoint")
+	proxyCmd.Flags().BoolVarP(&proxyUseCache, "use-cache", "", true, "use cache")
+	proxyCmd.Flags().StringVarP(&cacheKeyFile, "cache-key-file", "", "", "key file to encrypt the token cache. if not set, use COGLET_CACHE_KEY_FILE env or COGLET_CACHE_PASSPHRASE env")
+	_ = proxyCmd.MarkFlagRequired("target")
+}
+
+// tokenProvider holds the tokens of the users and renews them before they expire.
+type tokenProvider struct {
+	mu    sync.Mutex
+	users map[string]*userTokens
+	// allow reports whether the user can be switched to. If nil, all users are allowed.
+	allow func(username string) bool
+	// renew logs in as the user, or renews the current tokens if any
+	renew func(ctx context.Context, username string, current *tokenOutput) (tokenOutput, error)
+}
+
+// userTokens holds the tokens of a user.
+// Its lock is held while the tokens are renewed, so that requests of other users are not blocked.
+type userTokens struct {
+	mu       sync.Mutex
+	tokens   *tokenOutput
+	renewAt  time.Time
+	err      error
+	failedAt time.Time
+}
+
+func (p *tokenProvider) token(ctx context.Context, username string) (tokenOutput, error) {
+	if p.allow != nil && !p.allow(username) {
+		return tokenOutput{}, fmt.Errorf("%w: %s", errUnknownUser, username)
+	}
+	p.mu.Lock()
+	if p.users == nil {
+		p.users = map[string]*userTokens{}
+	}
+	u, ok := p.users[username]
+	if !ok {
+		u = &userTokens{}
+		p.users[username] = u
+	}
+	p.mu.Unlock()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.tokens != nil && time.Now().Before(u.renewAt) {
+		return *u.tokens, nil
+	}
+	if u.err != nil && time.Since(u.failedAt) < loginFailureTTL {
+		return tokenOutput{}, u.err
+	}
+	t, err := p.renew(ctx, username, u.tokens)
+	if err != nil && u.tokens != nil {
+		// the refresh token may be expired or revoked
+		t, err = p.renew(ctx, username, nil)
+	}
+	if err != nil {
+		u.err = err
+		u.failedAt = time.Now()
+		return tokenOutput{}, err
+	}
+	u.tokens = &t
+	u.renewAt = time.Now().Add(renewIn(t))
+	u.err = nil
+	return t, nil
+}
+
+// newProxyHandler returns a reverse proxy to the target that adds the bearer token of the user.
+// The user can be switched per request with userHeader, which is not forwarded to the target.
+func newProxyHandler(target *url.URL, defaultUser, userHeader, tokenType string, token func(ctx context.Context, username string) (tokenOutput, error)) http.Handler {
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			r.Out.Header.Del(userHeader)
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username := r.Header.Get(userHeader)
+		if username == "" {
+			username = defaultUser
+		}
+		t, err := token(r.Context(), username)
+		if errors.Is(err, errUnknownUser) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			slog.Error("failed to log in", slog.String("username", username), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("failed to log in as %s", username), http.StatusBadGateway)
+			return
+		}
+		bearer := t.AccessToken
+		if tokenType == "id" {
+			bearer = t.IDToken
+		}
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+bearer)
+		rp.ServeHTTP(w, r)
+	})
+}
+
+// readPasswords reads the usernames and passwords from the JSONL file in the format of apply-users.
+func readPasswords(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	passwords := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	var n int
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var user userpool.User
+		if err := json.Unmarshal([]byte(line), &user); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		passwords[user.Username] = user.Password
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return passwords, nil
+}
@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/internal/jwt"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/coglet/userpool/userpooltest"
+)
+
+func TestNewProxyHandler(t *testing.T) {
+	ctx := context.Background()
+	api := userpooltest.NewAPI()
+	id := api.CreateUserPool("test")
+	clientID, err := api.CreateUserPoolClient(id, "app", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.SetExplicitAuthFlows(id, clientID, types.ExplicitAuthFlowsTypeAllowUserPasswordAuth); err != nil {
+		t.Fatal(err)
+	}
+	up, err := userpool.New(id, userpool.WithAPI(api))
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwords := map[string]string{"alice": "Passw0rd!alice", "bob": "Passw0rd!bob"}
+	for username, pw := range passwords {
+		if err := up.ApplyUser(ctx, userpool.User{Username: username}, userpool.WithPassword(pw), userpool.WithPermanentPassword()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tp := &tokenProvider{
+		renew: func(ctx context.Context, username string, current *tokenOutput) (tokenOutput, error) {
+			out, err := up.LoginAs(ctx, userpool.User{Username: username, Password: passwords[username]}, userpool.WithClientIDOrName(clientID))
+			if err != nil {
+				return tokenOutput{}, err
+			}
+			return newTokenOutput(out.AuthenticationResult), nil
+		},
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Coglet-User") != "" {
+			http.Error(w, "user header is forwarded", http.StatusBadRequest)
+			return
+		}
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			http.Error(w, "no bearer token", http.StatusUnauthorized)
+			return
+		}
+		tk, err := jwt.Parse(bearer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		username := tk.String("username")
+		if username == "" {
+			// ID token
+			username = tk.String("cognito:username")
+		}
+		_, _ = io.WriteString(w, tk.String("token_use")+":"+username+":"+r.URL.Path)
+	}))
+	t.Cleanup(upstream.Close)
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		user       string
+		tokenType  string
+		wantStatus int
+		wantBody   string
+	}{
+		{"default user", "", "access", http.StatusOK, "access:alice:/path"},
+		{"switch user", "bob", "access", http.StatusOK, "access:bob:/path"},
+		{"id token", "bob", "id", http.StatusOK, "id:bob:/path"},
+		{"unknown user", "carol", "access", http.StatusBadGateway, "failed to log in as carol\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := httptest.NewServer(newProxyHandler(target, "alice", "X-Coglet-User", tt.tokenType, tp.token))
+			t.Cleanup(proxy.Close)
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, proxy.URL+"/path", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.user != "" {
+				req.Header.Set("X-Coglet-User", tt.user)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", res.StatusCode, tt.wantStatus, b)
+			}
+			if string(b) != tt.wantBody {
+				t.Errorf("got body %q, want %q", b, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTokenProviderDoesNotBlockOtherUsers(t *testing.T) {
+	release := make(chan struct{})
+	tp := &tokenProvider{
+		renew: func(ctx context.Context, username string, current *tokenOutput) (tokenOutput, error) {
+			if username == "slow" {
+				<-release
+			}
+			return tokenOutput{AccessToken: username, ExpiresIn: 3600}, nil
+		},
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = tp.token(context.Background(), "slow")
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := tp.token(context.Background(), "fast"); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("the login of a user is blocked by the login of another user")
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestTokenProviderCachesFailure(t *testing.T) {
+	var calls atomic.Int32
+	tp := &tokenProvider{
+		renew: func(ctx context.Context, username string, current *tokenOutput) (tokenOutput, error) {
+			calls.Add(1)
+			return tokenOutput{}, errors.New("login failed")
+		},
+	}
+	for range 3 {
+		if _, err := tp.token(context.Background(), "alice"); err == nil {
+			t.Fatal("want error")
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("got %d logins, want 1", got)
+	}
+	// the failure expires
+	tp.users["alice"].failedAt = time.Now().Add(-loginFailureTTL)
+	if _, err := tp.token(context.Background(), "alice"); err == nil {
+		t.Fatal("want error")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("got %d logins, want 2", got)
+	}
+}
+
+func TestTokenProviderRejectsUnknownUsers(t *testing.T) {
+	var calls atomic.Int32
+	tp := &tokenProvider{
+		allow: func(username string) bool {
+			return username == "alice"
+		},
+		renew: func(ctx context.Context, username string, current *tokenOutput) (tokenOutput, error) {
+			calls.Add(1)
+			return tokenOutput{AccessToken: username, ExpiresIn: 3600}, nil
+		},
+	}
+	if _, err := tp.token(context.Background(), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []string{"bob", "carol"} {
+		if _, err := tp.token(context.Background(), username); !errors.Is(err, errUnknownUser) {
+			t.Errorf("got %v, want %v", err, errUnknownUser)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("got %d logins, want 1", got)
+	}
+	// unknown users are not held
+	if len(tp.users) != 1 {
+		t.Errorf("got %d users, want 1", len(tp.users))
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(upstream.Close)
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(newProxyHandler(target, "alice", "X-Coglet-User", "access", tp.token))
+	t.Cleanup(proxy.Close)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, proxy.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Coglet-User", "bob")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestReadPasswords(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "users.jsonl")
+	content := `# users to switch to
+{"username":"alice","password":"Passw0rd!alice"}
+
+  # bob logs in with the cached tokens
+{"username":"bob"}
+`
+	if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readPasswords(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"alice": "Passw0rd!alice", "bob": ""}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(p, []byte("{\"username\":\"alice\"}\ninvalid\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPasswords(p); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got %v, want error of line 2", err)
+	}
+}
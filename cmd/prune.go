@@ -0,0 +1,107 @@
+/*
+Copyright © 2025 Ken'ichiro Oyama <k1lowxb@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/k1LoW/coglet/userpool"
+)
+
+const (
+	pruneActionDelete  = "delete"
+	pruneActionDisable = "disable"
+)
+
+// pruneCandidates returns usernames in the user pool that are not in the users file.
+// Users that do not match the filter are out of the scope of pruning.
+// Usernames in the users file may be aliases (email, phone number or sub), so they are resolved to the usernames in the user pool.
+func pruneCandidates(ctx context.Context, up *userpool.Client, seen map[string]struct{}, filterRe *regexp.Regexp, action string) ([]string, error) {
+	users, err := up.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exists := map[string]struct{}{}
+	for _, u := range users {
+		exists[aws.ToString(u.Username)] = struct{}{}
+	}
+	keep := map[string]struct{}{}
+	for name := range seen {
+		if _, ok := exists[name]; ok {
+			keep[name] = struct{}{}
+			continue
+		}
+		username, err := up.ResolveUsername(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if username != "" {
+			keep[username] = struct{}{}
+		}
+	}
+	var candidates []string
+	for _, u := range users {
+		username := aws.ToString(u.Username)
+		if _, ok := keep[username]; ok {
+			continue
+		}
+		if filterRe != nil && !filterRe.MatchString(username) {
+			continue
+		}
+		if action == pruneActionDisable && !u.Enabled {
+			continue
+		}
+		candidates = append(candidates, username)
+	}
+	return candidates, nil
+}
+
+func pruneUser(ctx context.Context, up *userpool.Client, username, action string) error {
+	switch action {
+	case pruneActionDelete:
+		return up.DeleteUser(ctx, username)
+	case pruneActionDisable:
+		return up.DisableUser(ctx, username)
+	default:
+		return fmt.Errorf("invalid prune action: %s", action)
+	}
+}
+
+func confirmPrune(in io.Reader, out io.Writer, kind string, names []string, action string) (bool, error) {
+	_, _ = fmt.Fprintf(out, "The following %s are not in the %s file and will be %sd:\n", kind, kind, action)
+	for _, n := range names {
+		_, _ = fmt.Fprintf(out, "  - %s\n", n)
+	}
+	_, _ = fmt.Fprintf(out, "Do you want to %s %d %s? Only 'yes' will be accepted: ", action, len(names), kind)
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return strings.TrimSpace(line) == "yes", nil
+}
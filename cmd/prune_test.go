@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/coglet/userpool/userpooltest"
+)
+
+func TestPruneCandidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		seen   []string
+		filter string
+		action string
+		want   []string
+	}{
+		{
+			name:   "delete users not in the users file",
+			seen:   []string{"alice"},
+			action: pruneActionDelete,
+			want:   []string{"bob", "carol", "test-dave"},
+		},
+		{
+			name:   "users out of the filter are not pruned",
+			seen:   []string{"test-alice"},
+			filter: "^test-",
+			action: pruneActionDelete,
+			want:   []string{"test-dave"},
+		},
+		{
+			name:   "disabled users are not disabled again",
+			seen:   []string{"alice"},
+			action: pruneActionDisable,
+			want:   []string{"bob", "test-dave"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api := userpooltest.NewAPI()
+			up, err := userpool.New(api.CreateUserPool("test"), userpool.WithAPI(api))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, u := range []userpool.User{
+				{Username: "alice"},
+				{Username: "bob"},
+				{Username: "carol", Enabled: aws.Bool(false)},
+				{Username: "test-dave"},
+			} {
+				if err := up.ApplyUser(ctx, u); err != nil {
+					t.Fatal(err)
+				}
+			}
+			seen := map[string]struct{}{}
+			for _, u := range tt.seen {
+				seen[u] = struct{}{}
+			}
+			var filterRe *regexp.Regexp
+			if tt.filter != "" {
+				filterRe = regexp.MustCompile(tt.filter)
+			}
+			got, err := pruneCandidates(ctx, up, seen, filterRe, tt.action)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneCandidatesUsernameAttributes(t *testing.T) {
+	ctx := context.Background()
+	api := userpooltest.NewAPI()
+	id := api.CreateUserPool("test")
+	if err := api.SetUsernameAttributes(id, types.UsernameAttributeTypeEmail); err != nil {
+		t.Fatal(err)
+	}
+	up, err := userpool.New(id, userpool.WithAPI(api))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := map[string]string{}
+	for _, email := range []string{"alice@example.com", "bob@example.com", "carol@example.com"} {
+		if err := up.ApplyUser(ctx, userpool.User{Username: email}); err != nil {
+			t.Fatal(err)
+		}
+		u, ok := api.GetUser(id, email)
+		if !ok {
+			t.Fatalf("user %s not found", email)
+		}
+		subs[email] = u.Username
+	}
+	// users in the users file are referred to by email or sub
+	seen := map[string]struct{}{
+		"alice@example.com":       {},
+		subs["bob@example.com"]:   {},
+		"not-created@example.com": {},
+	}
+	got, err := pruneCandidates(ctx, up, seen, nil, pruneActionDelete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{subs["carol@example.com"]}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
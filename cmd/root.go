@@ -22,6 +22,8 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 
@@ -37,8 +39,24 @@ var rootCmd = &cobra.Command{
 	Version:      version.Version,
 }
 
+// exitError makes coglet exit with the code, such as the exit code of the command run by exec.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		if errors.Is(err, errChangesPending) {
+			os.Exit(2)
+		}
+		var ee *exitError
+		if errors.As(err, &ee) {
+			os.Exit(ee.code)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,160 @@
+// Package jwt decodes JSON Web Tokens issued by Amazon Cognito and verifies their RS256 signatures with JWKS.
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Token is a decoded JWT. It is not verified until Verify is called.
+type Token struct {
+	Header    map[string]any
+	Claims    map[string]any
+	signed    string
+	signature []byte
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a JSON Web Key. Only RSA keys are supported.
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// Parse decodes the token without verifying it.
+func Parse(raw string) (*Token, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid token: must have 3 parts")
+	}
+	header, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	claims, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+	return &Token{
+		Header:    header,
+		Claims:    claims,
+		signed:    parts[0] + "." + parts[1],
+		signature: sig,
+	}, nil
+}
+
+// ParseJWKS parses the JSON Web Key Set.
+func ParseJWKS(b []byte) (*JWKS, error) {
+	jwks := &JWKS{}
+	if err := json.Unmarshal(b, jwks); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	return jwks, nil
+}
+
+// Time returns the time of the NumericDate claim such as exp, iat and auth_time.
+func (t *Token) Time(name string) (time.Time, bool) {
+	n, ok := t.Claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	v, err := n.Int64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(v, 0), true
+}
+
+// String returns the string claim.
+func (t *Token) String(name string) string {
+	s, _ := t.Claims[name].(string)
+	return s
+}
+
+// Verify verifies the RS256 signature of the token with the key of the kid in jwks.
+func (t *Token) Verify(jwks *JWKS) error {
+	if alg, _ := t.Header["alg"].(string); alg != "RS256" {
+		return fmt.Errorf("unsupported alg: %v", t.Header["alg"])
+	}
+	kid, _ := t.Header["kid"].(string)
+	for _, k := range jwks.Keys {
+		if k.Kid != kid {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256([]byte(t.signed))
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], t.signature); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("key not found in JWKS: %s", kid)
+}
+
+func (k JWK) publicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %s: %w", k.Kid, err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %s: %w", k.Kid, err)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// NewJWK returns the JWK of the RSA public key for RS256.
+func NewJWK(kid string, pub *rsa.PublicKey) JWK {
+	return JWK{
+		Kid: kid,
+		Kty: "RSA",
+		Alg: "RS256",
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func decodeSegment(s string) (map[string]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	m := map[string]any{}
+	if err := d.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
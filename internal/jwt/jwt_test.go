@@ -0,0 +1,180 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(t *testing.T, key *rsa.PrivateKey, header, claims map[string]any) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestParse(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := sign(t, key, map[string]any{"alg": "RS256", "kid": "k1"}, map[string]any{
+		"sub":       "alice",
+		"exp":       1700000000,
+		"token_use": "id",
+	})
+	tk, err := Parse(" " + raw + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tk.String("sub"); got != "alice" {
+		t.Errorf("got sub %q", got)
+	}
+	if got := tk.String("exp"); got != "" {
+		t.Errorf("got %q for a number claim, want empty", got)
+	}
+	exp, ok := tk.Time("exp")
+	if !ok || !exp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("got exp %v, %v", exp, ok)
+	}
+	if _, ok := tk.Time("sub"); ok {
+		t.Error("want false for a string claim")
+	}
+	if _, ok := tk.Time("nbf"); ok {
+		t.Error("want false for a missing claim")
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"two parts", "a.b"},
+		{"invalid header", "!.e30.AA"},
+		{"invalid claims", "e30.!.AA"},
+		{"claims not object", "e30.WzFd.AA"},
+		{"invalid signature", "e30.e30.!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.raw); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(JWKS{Keys: []JWK{NewJWK("k0", &other.PublicKey), NewJWK("k1", &key.PublicKey)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := ParseJWKS(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]any{"sub": "alice"}
+	valid := sign(t, key, map[string]any{"alg": "RS256", "kid": "k1"}, claims)
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory"}`)) + "." + parts[2]
+
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"signed by another key", sign(t, other, map[string]any{"alg": "RS256", "kid": "k1"}, claims), true},
+		{"unknown kid", sign(t, key, map[string]any{"alg": "RS256", "kid": "k2"}, claims), true},
+		{"alg none", sign(t, key, map[string]any{"alg": "none", "kid": "k1"}, claims), true},
+		{"alg HS256", sign(t, key, map[string]any{"alg": "HS256", "kid": "k1"}, claims), true},
+		{"tampered claims", tampered, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = tk.Verify(jwks)
+			if tt.wantErr && err == nil {
+				t.Error("want error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestVerifyUnsupportedKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk, err := Parse(sign(t, key, map[string]any{"alg": "RS256", "kid": "k1"}, map[string]any{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		key  JWK
+	}{
+		{"EC key", JWK{Kid: "k1", Kty: "EC"}},
+		{"invalid n", JWK{Kid: "k1", Kty: "RSA", N: "!", E: "AQAB"}},
+		{"invalid e", JWK{Kid: "k1", Kty: "RSA", N: "AQAB", E: "!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tk.Verify(&JWKS{Keys: []JWK{tt.key}}); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	if _, err := ParseJWKS([]byte("{")); err == nil {
+		t.Error("want error")
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := NewJWK("k1", &key.PublicKey)
+	if jwk.E != "AQAB" {
+		t.Errorf("got e %s, want AQAB", jwk.E)
+	}
+	pub, err := jwk.publicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.Equal(&key.PublicKey) {
+		t.Error("public key does not round trip")
+	}
+}
@@ -0,0 +1,133 @@
+// Package srp provides the SRP-6a primitives used by the USER_SRP_AUTH flow of Amazon Cognito.
+package srp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// nHex is the 3072-bit group of RFC 5054 used by Amazon Cognito.
+const nHex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+	"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+	"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+	"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D" +
+	"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F" +
+	"83655D23DCA3AD961C62F356208552BB9ED529077096966D" +
+	"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9" +
+	"DE2BCBF6955817183995497CEA956AE515D2261898FA0510" +
+	"15728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64" +
+	"ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6B" +
+	"F12FFA06D98A0864D87602733EC86A64521F2B18177B200C" +
+	"BBE117577A615D6C770988C0BAD946E208E24FA074E5AB31" +
+	"43DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF"
+
+const derivedKeyInfo = "Caldera Derived Key"
+
+// TimestampFormat is the format of TIMESTAMP in the PASSWORD_VERIFIER challenge response.
+const TimestampFormat = "Mon Jan 2 15:04:05 UTC 2006"
+
+var (
+	N = mustParseHex(nHex)
+	G = big.NewInt(2)
+	K = HexHash(PadHex(N) + PadHex(G))
+)
+
+// PadHex returns the hex string of n padded so that it is interpreted as a positive number.
+func PadHex(n *big.Int) string {
+	h := n.Text(16)
+	if len(h)%2 == 1 {
+		return "0" + h
+	}
+	if strings.ContainsRune("89abcdef", rune(h[0])) {
+		return "00" + h
+	}
+	return h
+}
+
+// HexHash returns SHA-256 of the bytes represented by the hex string as a number.
+func HexHash(h string) *big.Int {
+	b, _ := hex.DecodeString(h)
+	sum := sha256.Sum256(b)
+	return new(big.Int).SetBytes(sum[:])
+}
+
+// Hash returns the hex string of SHA-256 of s.
+func Hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// RandomInt returns a random 1024-bit number.
+func RandomInt() (*big.Int, error) {
+	b := make([]byte, 128)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// U returns the scrambling parameter u = H(PAD(A) | PAD(B)).
+func U(a, b *big.Int) *big.Int {
+	return HexHash(PadHex(a) + PadHex(b))
+}
+
+// X returns the private key x = H(PAD(salt) | H(poolName | username | ":" | password)).
+func X(salt *big.Int, poolName, username, password string) *big.Int {
+	return HexHash(PadHex(salt) + Hash(poolName+username+":"+password))
+}
+
+// DerivedKey returns the 16-byte key derived from the premaster secret s and u by HKDF.
+func DerivedKey(s, u *big.Int) []byte {
+	ikm, _ := hex.DecodeString(PadHex(s))
+	salt, _ := hex.DecodeString(PadHex(u))
+	prk := hmac.New(sha256.New, salt)
+	prk.Write(ikm)
+	h := hmac.New(sha256.New, prk.Sum(nil))
+	h.Write([]byte(derivedKeyInfo))
+	h.Write([]byte{1})
+	return h.Sum(nil)[:16]
+}
+
+// Timestamp returns TIMESTAMP of the PASSWORD_VERIFIER challenge response.
+func Timestamp(t time.Time) string {
+	return t.UTC().Format(TimestampFormat)
+}
+
+// Signature returns PASSWORD_CLAIM_SIGNATURE.
+func Signature(key []byte, poolName, userID, secretBlock, timestamp string) (string, error) {
+	block, err := base64.StdEncoding.DecodeString(secretBlock)
+	if err != nil {
+		return "", err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(poolName))
+	h.Write([]byte(userID))
+	h.Write(block)
+	h.Write([]byte(timestamp))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// PoolName returns the part of the user pool ID after the region.
+func PoolName(userPoolID string) string {
+	if _, after, ok := strings.Cut(userPoolID, "_"); ok {
+		return after
+	}
+	return userPoolID
+}
+
+func mustParseHex(h string) *big.Int {
+	n, ok := new(big.Int).SetString(h, 16)
+	if !ok {
+		panic("invalid hex: " + h)
+	}
+	return n
+}
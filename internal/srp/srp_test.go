@@ -0,0 +1,161 @@
+package srp
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestK(t *testing.T) {
+	// k = H(PAD(N) | PAD(g)) of the 3072-bit group, as used by the Amazon Cognito SDKs
+	want := "538282c4354742d7cbbde2359fcf67f9f5b3a6b08791e5011b43b8a5b66d9ee6"
+	if got := K.Text(16); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestPadHex(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0x1, "01"},
+		{0x2, "02"},
+		{0x7f, "7f"},
+		{0x80, "0080"},
+		{0xff, "00ff"},
+		{0x100, "0100"},
+		{0x7fff, "7fff"},
+		{0x8000, "008000"},
+	}
+	for _, tt := range tests {
+		if got := PadHex(big.NewInt(tt.n)); got != tt.want {
+			t.Errorf("PadHex(%#x) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	// FIPS 180-2 test vector
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := Hash("abc"); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got := HexHash("616263").Text(16); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDerivedKey(t *testing.T) {
+	s := new(big.Int).Exp(G, big.NewInt(12345), N)
+	u := big.NewInt(0xabcdef)
+	ikm := s.Bytes()
+	if ikm[0]&0x80 != 0 {
+		// padded so that it is interpreted as a positive number
+		ikm = append([]byte{0x00}, ikm...)
+	}
+	want, err := hkdf.Key(sha256.New, ikm, []byte{0x00, 0xab, 0xcd, 0xef}, derivedKeyInfo, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DerivedKey(s, u); !hmac.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
+
+func TestSessionKey(t *testing.T) {
+	const (
+		poolName = "abcdefgh"
+		username = "alice"
+		password = "Passw0rd!"
+	)
+	salt, err := RandomInt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := RandomInt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := RandomInt()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// server
+	v := new(big.Int).Exp(G, X(salt, poolName, username, password), N)
+	B := new(big.Int).Add(new(big.Int).Mul(K, v), new(big.Int).Exp(G, b, N))
+	B.Mod(B, N)
+
+	// client
+	A := new(big.Int).Exp(G, a, N)
+	u := U(A, B)
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"correct password", password, true},
+		{"wrong password", "wrong", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := X(salt, poolName, username, tt.password)
+			base := new(big.Int).Sub(B, new(big.Int).Mul(K, new(big.Int).Exp(G, x, N)))
+			base.Mod(base, N)
+			exp := new(big.Int).Add(a, new(big.Int).Mul(u, x))
+			clientS := new(big.Int).Exp(base, exp, N)
+
+			serverS := new(big.Int).Mul(A, new(big.Int).Exp(v, u, N))
+			serverS.Exp(serverS, b, N)
+
+			got := hmac.Equal(DerivedKey(clientS, u), DerivedKey(serverS, u))
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignature(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	block := []byte("secret block")
+	timestamp := Timestamp(time.Date(2025, 3, 4, 5, 6, 7, 0, time.FixedZone("JST", 9*60*60)))
+	if want := "Mon Mar 3 20:06:07 UTC 2025"; timestamp != want {
+		t.Errorf("got timestamp %q, want %q", timestamp, want)
+	}
+	got, err := Signature(key, "abcdefgh", "alice", base64.StdEncoding.EncodeToString(block), timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("abcdefghalice"))
+	h.Write(block)
+	h.Write([]byte(timestamp))
+	if want := base64.StdEncoding.EncodeToString(h.Sum(nil)); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, err := Signature(key, "abcdefgh", "alice", "!invalid", timestamp); err == nil {
+		t.Error("want error for invalid secret block")
+	}
+}
+
+func TestPoolName(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"ap-northeast-1_abcdefgh", "abcdefgh"},
+		{"abcdefgh", "abcdefgh"},
+	}
+	for _, tt := range tests {
+		if got := PoolName(tt.id); got != tt.want {
+			t.Errorf("PoolName(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package totp implements TOTP (RFC 6238) with the parameters used by Amazon Cognito software tokens.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	period = 30
+	digits = 6
+)
+
+// Code returns the TOTP code of the base32 encoded secret at t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, counter(t)), nil
+}
+
+// Validate reports whether the code is valid at t, allowing one period of clock skew.
+func Validate(secret, c string, t time.Time) bool {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return false
+	}
+	n := counter(t)
+	for _, i := range []uint64{n - 1, n, n + 1} {
+		if hmac.Equal([]byte(code(key, i)), []byte(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// URI returns the otpauth:// URI of the secret to be registered with authenticator apps.
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(digits))
+	v.Set("period", fmt.Sprint(period))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawPath:  "/" + url.PathEscape(issuer) + ":" + url.PathEscape(account),
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+func counter(t time.Time) uint64 {
+	return uint64(t.Unix() / period) //nolint:gosec
+}
+
+func code(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	h := hmac.New(sha1.New, key)
+	h.Write(msg)
+	sum := h.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, v%1000000)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	s = strings.TrimRight(s, "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,124 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// secret is the base32 encoded seed "12345678901234567890" of the SHA1 test vectors of RFC 6238.
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// the last 6 digits of the 8-digit codes of RFC 6238 Appendix B
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeSecretFormat(t *testing.T) {
+	tests := []struct {
+		secret  string
+		wantErr bool
+	}{
+		{"gezdgnbvgy3tqojqgezdgnbvgy3tqojq", false},
+		{"GEZD GNBV GY3T QOJQ GEZD GNBV GY3T QOJQ", false},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ====", false},
+		{"not base32!", true},
+	}
+	for _, tt := range tests {
+		got, err := Code(tt.secret, time.Unix(59, 0))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Code(%q): want error", tt.secret)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Code(%q): %v", tt.secret, err)
+			continue
+		}
+		if got != "287082" {
+			t.Errorf("Code(%q) = %s, want 287082", tt.secret, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"current period", now, true},
+		{"previous period", now.Add(-period * time.Second), true},
+		{"next period", now.Add(period * time.Second), true},
+		{"two periods ago", now.Add(-2 * period * time.Second), false},
+		{"two periods later", now.Add(2 * period * time.Second), false},
+	}
+	c, err := Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Validate(secret, c, tt.at); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if Validate("not base32!", c, now) {
+		t.Error("want false for invalid secret")
+	}
+}
+
+func TestURI(t *testing.T) {
+	tests := []struct {
+		issuer   string
+		account  string
+		wantPath string
+	}{
+		{"MyUserPool", "alice", "/MyUserPool:alice"},
+		{"My User Pool", "alice@example.com", "/My%20User%20Pool:alice@example.com"},
+		{"pool/dev", "a/b?c#d", "/pool%2Fdev:a%2Fb%3Fc%23d"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(URI(tt.issuer, tt.account, secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Scheme != "otpauth" || u.Host != "totp" {
+			t.Errorf("got %s", u)
+		}
+		if got := u.EscapedPath(); got != tt.wantPath {
+			t.Errorf("got path %s, want %s", got, tt.wantPath)
+		}
+		if got, want := u.Path, "/"+tt.issuer+":"+tt.account; got != want {
+			t.Errorf("got unescaped path %s, want %s", got, want)
+		}
+		q := u.Query()
+		for k, want := range map[string]string{"secret": secret, "issuer": tt.issuer, "algorithm": "SHA1", "digits": "6", "period": "30"} {
+			if got := q.Get(k); got != want {
+				t.Errorf("%s = %q, want %q", k, got, want)
+			}
+		}
+	}
+}
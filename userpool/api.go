@@ -0,0 +1,40 @@
+package userpool
+
+import (
+	"context"
+
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+)
+
+// API is the subset of the Amazon Cognito Identity Provider API used by Client.
+// *cognito.Client satisfies it, and userpooltest provides an in-memory implementation.
+type API interface {
+	AdminGetUser(ctx context.Context, params *cognito.AdminGetUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminGetUserOutput, error)
+	AdminCreateUser(ctx context.Context, params *cognito.AdminCreateUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminCreateUserOutput, error)
+	AdminUpdateUserAttributes(ctx context.Context, params *cognito.AdminUpdateUserAttributesInput, optFns ...func(*cognito.Options)) (*cognito.AdminUpdateUserAttributesOutput, error)
+	AdminSetUserPassword(ctx context.Context, params *cognito.AdminSetUserPasswordInput, optFns ...func(*cognito.Options)) (*cognito.AdminSetUserPasswordOutput, error)
+	AdminAddUserToGroup(ctx context.Context, params *cognito.AdminAddUserToGroupInput, optFns ...func(*cognito.Options)) (*cognito.AdminAddUserToGroupOutput, error)
+	AdminRemoveUserFromGroup(ctx context.Context, params *cognito.AdminRemoveUserFromGroupInput, optFns ...func(*cognito.Options)) (*cognito.AdminRemoveUserFromGroupOutput, error)
+	AdminListGroupsForUser(ctx context.Context, params *cognito.AdminListGroupsForUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminListGroupsForUserOutput, error)
+	AdminDeleteUser(ctx context.Context, params *cognito.AdminDeleteUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminDeleteUserOutput, error)
+	AdminDisableUser(ctx context.Context, params *cognito.AdminDisableUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminDisableUserOutput, error)
+	AdminEnableUser(ctx context.Context, params *cognito.AdminEnableUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminEnableUserOutput, error)
+	AdminSetUserMFAPreference(ctx context.Context, params *cognito.AdminSetUserMFAPreferenceInput, optFns ...func(*cognito.Options)) (*cognito.AdminSetUserMFAPreferenceOutput, error)
+	AdminResetUserPassword(ctx context.Context, params *cognito.AdminResetUserPasswordInput, optFns ...func(*cognito.Options)) (*cognito.AdminResetUserPasswordOutput, error)
+	GetGroup(ctx context.Context, params *cognito.GetGroupInput, optFns ...func(*cognito.Options)) (*cognito.GetGroupOutput, error)
+	CreateGroup(ctx context.Context, params *cognito.CreateGroupInput, optFns ...func(*cognito.Options)) (*cognito.CreateGroupOutput, error)
+	UpdateGroup(ctx context.Context, params *cognito.UpdateGroupInput, optFns ...func(*cognito.Options)) (*cognito.UpdateGroupOutput, error)
+	DeleteGroup(ctx context.Context, params *cognito.DeleteGroupInput, optFns ...func(*cognito.Options)) (*cognito.DeleteGroupOutput, error)
+	ListGroups(ctx context.Context, params *cognito.ListGroupsInput, optFns ...func(*cognito.Options)) (*cognito.ListGroupsOutput, error)
+	DescribeUserPool(ctx context.Context, params *cognito.DescribeUserPoolInput, optFns ...func(*cognito.Options)) (*cognito.DescribeUserPoolOutput, error)
+	ListUsers(ctx context.Context, params *cognito.ListUsersInput, optFns ...func(*cognito.Options)) (*cognito.ListUsersOutput, error)
+	ListUserPools(ctx context.Context, params *cognito.ListUserPoolsInput, optFns ...func(*cognito.Options)) (*cognito.ListUserPoolsOutput, error)
+	ListUserPoolClients(ctx context.Context, params *cognito.ListUserPoolClientsInput, optFns ...func(*cognito.Options)) (*cognito.ListUserPoolClientsOutput, error)
+	DescribeUserPoolClient(ctx context.Context, params *cognito.DescribeUserPoolClientInput, optFns ...func(*cognito.Options)) (*cognito.DescribeUserPoolClientOutput, error)
+	InitiateAuth(ctx context.Context, params *cognito.InitiateAuthInput, optFns ...func(*cognito.Options)) (*cognito.InitiateAuthOutput, error)
+	RespondToAuthChallenge(ctx context.Context, params *cognito.RespondToAuthChallengeInput, optFns ...func(*cognito.Options)) (*cognito.RespondToAuthChallengeOutput, error)
+	AssociateSoftwareToken(ctx context.Context, params *cognito.AssociateSoftwareTokenInput, optFns ...func(*cognito.Options)) (*cognito.AssociateSoftwareTokenOutput, error)
+	VerifySoftwareToken(ctx context.Context, params *cognito.VerifySoftwareTokenInput, optFns ...func(*cognito.Options)) (*cognito.VerifySoftwareTokenOutput, error)
+}
+
+var _ API = (*cognito.Client)(nil)
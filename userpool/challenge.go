@@ -0,0 +1,130 @@
+package userpool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/internal/totp"
+	"github.com/k1LoW/coglet/version"
+)
+
+// respondToChallenges responds to the challenges that can be answered with the options.
+// The output is returned as is when a challenge cannot be answered.
+func (c *Client) respondToChallenges(ctx context.Context, ac *appClient, user User, opt LoginAsOption, out *cognito.InitiateAuthOutput) (*cognito.InitiateAuthOutput, error) {
+	for out.AuthenticationResult == nil {
+		var (
+			responses map[string]string
+			err       error
+		)
+		session := out.Session
+		switch out.ChallengeName {
+		case types.ChallengeNameTypeNewPasswordRequired:
+			if opt.NewPassword == "" {
+				return out, nil
+			}
+			responses, err = newPasswordResponses(user, opt.NewPassword, out.ChallengeParameters)
+		case types.ChallengeNameTypeSelectMfaType:
+			if opt.TOTPSecret == "" {
+				return out, nil
+			}
+			responses = map[string]string{
+				"ANSWER": string(types.ChallengeNameTypeSoftwareTokenMfa),
+			}
+		case types.ChallengeNameTypeSoftwareTokenMfa:
+			if opt.TOTPSecret == "" {
+				return out, nil
+			}
+			var code string
+			code, err = totp.Code(opt.TOTPSecret, time.Now())
+			responses = map[string]string{
+				"SOFTWARE_TOKEN_MFA_CODE": code,
+			}
+		case types.ChallengeNameTypeMfaSetup:
+			if opt.SetupTOTP == nil || !strings.Contains(out.ChallengeParameters["MFAS_CAN_SETUP"], string(types.ChallengeNameTypeSoftwareTokenMfa)) {
+				return out, nil
+			}
+			var secret string
+			secret, session, err = c.setupTOTP(ctx, session)
+			if err != nil {
+				return nil, err
+			}
+			if err := opt.SetupTOTP(secret); err != nil {
+				return nil, err
+			}
+			opt.TOTPSecret = secret
+			responses = map[string]string{}
+		default:
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		username := out.ChallengeParameters["USER_ID_FOR_SRP"]
+		if username == "" {
+			username = user.Username
+		}
+		responses["USERNAME"] = username
+		if ac.secret != "" {
+			responses["SECRET_HASH"] = secretHash(ac.id, ac.secret, username)
+		}
+		out, err = c.respondToAuthChallenge(ctx, ac, user, out.ChallengeName, session, responses)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// setupTOTP associates a software token in the MFA_SETUP challenge and verifies it.
+func (c *Client) setupTOTP(ctx context.Context, session *string) (string, *string, error) {
+	assoc, err := c.client.AssociateSoftwareToken(ctx, &cognito.AssociateSoftwareTokenInput{
+		Session: session,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	secret := aws.ToString(assoc.SecretCode)
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	verified, err := c.client.VerifySoftwareToken(ctx, &cognito.VerifySoftwareTokenInput{
+		Session:            assoc.Session,
+		UserCode:           aws.String(code),
+		FriendlyDeviceName: aws.String(version.Name),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if verified.Status != types.VerifySoftwareTokenResponseTypeSuccess {
+		return "", nil, fmt.Errorf("failed to verify software token: %s", verified.Status)
+	}
+	return secret, verified.Session, nil
+}
+
+func newPasswordResponses(user User, newPassword string, params map[string]string) (map[string]string, error) {
+	responses := map[string]string{
+		"NEW_PASSWORD": newPassword,
+	}
+	var required []string
+	if v := params["requiredAttributes"]; v != "" {
+		if err := json.Unmarshal([]byte(v), &required); err != nil {
+			return nil, fmt.Errorf("invalid required attributes: %w", err)
+		}
+	}
+	for _, r := range required {
+		name := strings.TrimPrefix(r, "userAttributes.")
+		v, ok := user.Attributes[name]
+		if !ok {
+			return nil, fmt.Errorf("required attribute is missing: %s", name)
+		}
+		responses["userAttributes."+name] = attributeValue(v)
+	}
+	return responses, nil
+}
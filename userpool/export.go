@@ -0,0 +1,116 @@
+package userpool
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+// MFA is the MFA preference of the user. It is exported for reference and is not applied by ApplyUser.
+type MFA struct {
+	Preferred string   `json:"preferred,omitempty"`
+	Enabled   []string `json:"enabled,omitempty"`
+}
+
+type ExportUserOption struct {
+	Groups bool
+	MFA    bool
+}
+
+type ExportUserOptionFunc func(*ExportUserOption) error
+
+// readOnlyAttributes are attributes that cannot be applied to another user pool.
+var readOnlyAttributes = []string{"sub", "identities"}
+
+// federatedReadOnlyAttributes are attributes that are managed by the identity provider for federated users.
+var federatedReadOnlyAttributes = []string{"email_verified", "phone_number_verified"}
+
+func WithGroups() ExportUserOptionFunc {
+	return func(opt *ExportUserOption) error {
+		opt.Groups = true
+		return nil
+	}
+}
+
+func WithMFA() ExportUserOptionFunc {
+	return func(opt *ExportUserOption) error {
+		opt.MFA = true
+		return nil
+	}
+}
+
+// ExportUser converts the user in the user pool into User that can be applied with ApplyUser.
+func (c *Client) ExportUser(ctx context.Context, u types.UserType, opts ...ExportUserOptionFunc) (User, error) {
+	var opt ExportUserOption
+	for _, o := range opts {
+		if err := o(&opt); err != nil {
+			return User{}, err
+		}
+	}
+	immutable, err := c.immutableAttributes(ctx)
+	if err != nil {
+		return User{}, err
+	}
+	user := User{
+		Username:   aws.ToString(u.Username),
+		Attributes: map[string]any{},
+		Enabled:    aws.Bool(u.Enabled),
+	}
+	federated := slices.ContainsFunc(u.Attributes, func(attr types.AttributeType) bool {
+		return aws.ToString(attr.Name) == "identities"
+	})
+	for _, attr := range u.Attributes {
+		name := aws.ToString(attr.Name)
+		switch {
+		case slices.Contains(readOnlyAttributes, name),
+			strings.HasPrefix(name, "cognito:"),
+			immutable[name],
+			federated && slices.Contains(federatedReadOnlyAttributes, name):
+			continue
+		}
+		user.Attributes[name] = aws.ToString(attr.Value)
+	}
+	if opt.Groups {
+		groups, err := c.userGroups(ctx, user.Username)
+		if err != nil {
+			return User{}, err
+		}
+		user.Groups = append([]string{}, groups...)
+	}
+	if opt.MFA {
+		mfa, err := c.UserMFA(ctx, user.Username)
+		if err != nil {
+			return User{}, err
+		}
+		user.MFA = mfa
+	}
+	return user, nil
+}
+
+// immutableAttributes returns the attributes that are not mutable in the schema of the user pool.
+// The schema is read once and cached.
+func (c *Client) immutableAttributes(ctx context.Context) (map[string]bool, error) {
+	c.schemaMu.Lock()
+	defer c.schemaMu.Unlock()
+	if c.immutable != nil {
+		return c.immutable, nil
+	}
+	out, err := c.client.DescribeUserPool(ctx, &cognito.DescribeUserPoolInput{
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	immutable := map[string]bool{}
+	for _, a := range out.UserPool.SchemaAttributes {
+		if !aws.ToBool(a.Mutable) {
+			immutable[aws.ToString(a.Name)] = true
+		}
+	}
+	c.immutable = immutable
+	return immutable, nil
+}
@@ -0,0 +1,87 @@
+package userpool_test
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/k1LoW/coglet/userpool"
+)
+
+func TestExportUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		immutable []string
+		attrs     map[string]string
+		direct    map[string]string
+		want      map[string]any
+	}{
+		{
+			name:  "attributes",
+			attrs: map[string]string{"email": "alice@example.com", "name": "Alice"},
+			want:  map[string]any{"email": "alice@example.com", "name": "Alice"},
+		},
+		{
+			name:      "immutable attributes",
+			immutable: []string{"custom:tenant"},
+			attrs:     map[string]string{"email": "alice@example.com"},
+			direct:    map[string]string{"custom:tenant": "t1"},
+			want:      map[string]any{"email": "alice@example.com"},
+		},
+		{
+			name:   "federated user",
+			attrs:  map[string]string{"email": "alice@example.com"},
+			direct: map[string]string{"identities": `[{"providerName":"Google"}]`, "email_verified": "true"},
+			want:   map[string]any{"email": "alice@example.com"},
+		},
+		{
+			name:   "email_verified of non-federated user",
+			attrs:  map[string]string{"email": "alice@example.com"},
+			direct: map[string]string{"email_verified": "true"},
+			want:   map[string]any{"email": "alice@example.com", "email_verified": "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			if err := api.SetImmutableAttributes(id, tt.immutable...); err != nil {
+				t.Fatal(err)
+			}
+			attrs := map[string]any{}
+			for k, v := range tt.attrs {
+				attrs[k] = v
+			}
+			if err := up.ApplyUser(ctx, userpool.User{Username: "alice", Attributes: attrs}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.direct != nil {
+				if err := api.SetUserAttributes(id, "alice", tt.direct); err != nil {
+					t.Fatal(err)
+				}
+			}
+			users, err := up.ListUsers(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != 1 {
+				t.Fatalf("got %d users, want 1", len(users))
+			}
+			got, err := up.ExportUser(ctx, users[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Username != "alice" || !aws.ToBool(got.Enabled) {
+				t.Errorf("got %+v", got)
+			}
+			if !maps.Equal(got.Attributes, tt.want) {
+				t.Errorf("got attributes %v, want %v", got.Attributes, tt.want)
+			}
+			// the exported user can be applied again
+			if err := up.ApplyUser(ctx, got); err != nil {
+				t.Errorf("apply exported user: %v", err)
+			}
+		})
+	}
+}
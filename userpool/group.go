@@ -0,0 +1,118 @@
+package userpool
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+// Group is a group of the user pool. Fields that are nil are not managed, and keep the current values on update.
+type Group struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	Precedence  *int32  `json:"precedence,omitempty"`
+	RoleArn     *string `json:"roleArn,omitempty"`
+}
+
+// ApplyGroup creates the group, or updates it when the group already exists and the fields set in group differ.
+func (c *Client) ApplyGroup(ctx context.Context, group Group) error {
+	if group.Name == "" {
+		return errors.New("group name is required")
+	}
+	current, err := c.client.GetGroup(ctx, &cognito.GetGroupInput{
+		UserPoolId: aws.String(c.userPoolID),
+		GroupName:  aws.String(group.Name),
+	})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			return err
+		}
+		// create group
+		_, err := c.client.CreateGroup(ctx, &cognito.CreateGroupInput{
+			UserPoolId:  aws.String(c.userPoolID),
+			GroupName:   aws.String(group.Name),
+			Description: optionalString(aws.ToString(group.Description)),
+			Precedence:  group.Precedence,
+			RoleArn:     optionalString(aws.ToString(group.RoleArn)),
+		})
+		return err
+	}
+	// UpdateGroup resets the fields not given, so fill the unmanaged fields with the current values
+	desired := Group{
+		Name:        group.Name,
+		Description: current.Group.Description,
+		Precedence:  current.Group.Precedence,
+		RoleArn:     current.Group.RoleArn,
+	}
+	if group.Description != nil {
+		desired.Description = group.Description
+	}
+	if group.Precedence != nil {
+		desired.Precedence = group.Precedence
+	}
+	if group.RoleArn != nil {
+		desired.RoleArn = group.RoleArn
+	}
+	if aws.ToString(current.Group.Description) == aws.ToString(desired.Description) &&
+		aws.ToString(current.Group.RoleArn) == aws.ToString(desired.RoleArn) &&
+		equalInt32(current.Group.Precedence, desired.Precedence) {
+		return nil
+	}
+	// update group
+	_, err = c.client.UpdateGroup(ctx, &cognito.UpdateGroupInput{
+		UserPoolId:  aws.String(c.userPoolID),
+		GroupName:   aws.String(group.Name),
+		Description: optionalString(aws.ToString(desired.Description)),
+		Precedence:  desired.Precedence,
+		RoleArn:     optionalString(aws.ToString(desired.RoleArn)),
+	})
+	return err
+}
+
+// ListGroups returns all groups in the user pool.
+func (c *Client) ListGroups(ctx context.Context) ([]types.GroupType, error) {
+	var groups []types.GroupType
+	var nextToken *string
+	for {
+		resp, err := c.client.ListGroups(ctx, &cognito.ListGroupsInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Limit:      aws.Int32(60),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, resp.Groups...)
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+	return groups, nil
+}
+
+func (c *Client) DeleteGroup(ctx context.Context, name string) error {
+	_, err := c.client.DeleteGroup(ctx, &cognito.DeleteGroupInput{
+		UserPoolId: aws.String(c.userPoolID),
+		GroupName:  aws.String(name),
+	})
+	return err
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+func equalInt32(a, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,97 @@
+package userpool_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/coglet/userpool/userpooltest"
+)
+
+func TestApplyGroup(t *testing.T) {
+	const roleArn = "arn:aws:iam::123456789012:role/AdminRole"
+	tests := []struct {
+		name           string
+		current        *userpool.Group
+		group          userpool.Group
+		wantDesc       string
+		wantPrecedence *int32
+		wantRoleArn    string
+		wantModified   bool
+	}{
+		{
+			name:           "create",
+			group:          userpool.Group{Name: "admin", Description: aws.String("Administrators"), Precedence: aws.Int32(1)},
+			wantDesc:       "Administrators",
+			wantPrecedence: aws.Int32(1),
+		},
+		{
+			name:           "unset fields keep the current values",
+			current:        &userpool.Group{Name: "admin", Description: aws.String("Administrators"), Precedence: aws.Int32(1), RoleArn: aws.String(roleArn)},
+			group:          userpool.Group{Name: "admin"},
+			wantDesc:       "Administrators",
+			wantPrecedence: aws.Int32(1),
+			wantRoleArn:    roleArn,
+		},
+		{
+			name:         "update only the set field",
+			current:      &userpool.Group{Name: "admin", Description: aws.String("Administrators"), RoleArn: aws.String(roleArn)},
+			group:        userpool.Group{Name: "admin", Description: aws.String("Admins")},
+			wantDesc:     "Admins",
+			wantRoleArn:  roleArn,
+			wantModified: true,
+		},
+		{
+			name:         "clear with an empty value",
+			current:      &userpool.Group{Name: "admin", Description: aws.String("Administrators")},
+			group:        userpool.Group{Name: "admin", Description: aws.String("")},
+			wantModified: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api := userpooltest.NewAPI()
+			up, err := userpool.New(api.CreateUserPool("test"), userpool.WithAPI(api))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.current != nil {
+				if err := up.ApplyGroup(ctx, *tt.current); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before, err := up.ListGroups(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := up.ApplyGroup(ctx, tt.group); err != nil {
+				t.Fatal(err)
+			}
+			groups, err := up.ListGroups(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(groups) != 1 {
+				t.Fatalf("got %d groups", len(groups))
+			}
+			g := groups[0]
+			if got := aws.ToString(g.Description); got != tt.wantDesc {
+				t.Errorf("description: got %q, want %q", got, tt.wantDesc)
+			}
+			if got := aws.ToString(g.RoleArn); got != tt.wantRoleArn {
+				t.Errorf("roleArn: got %q, want %q", got, tt.wantRoleArn)
+			}
+			if aws.ToInt32(g.Precedence) != aws.ToInt32(tt.wantPrecedence) || (g.Precedence == nil) != (tt.wantPrecedence == nil) {
+				t.Errorf("precedence: got %v, want %v", g.Precedence, tt.wantPrecedence)
+			}
+			if len(before) == 1 {
+				modified := !aws.ToTime(g.LastModifiedDate).Equal(aws.ToTime(before[0].LastModifiedDate))
+				if modified != tt.wantModified {
+					t.Errorf("modified: got %v, want %v", modified, tt.wantModified)
+				}
+			}
+		})
+	}
+}
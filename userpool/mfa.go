@@ -0,0 +1,105 @@
+package userpool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/internal/totp"
+	"github.com/k1LoW/coglet/version"
+)
+
+// EnrollSoftwareToken logs in as the user, associates a new software token and verifies it with a computed TOTP code,
+// then sets software token MFA as the preferred MFA of the user. It returns the secret of the software token.
+// When the MFA_SETUP challenge is returned on login, the software token is set up in the challenge.
+func (c *Client) EnrollSoftwareToken(ctx context.Context, user User, opts ...LoginAsOptionFunc) (string, error) {
+	var secret string
+	opts = append(opts, WithSetupTOTP(func(s string) error {
+		secret = s
+		return nil
+	}))
+	out, err := c.LoginAs(ctx, user, opts...)
+	if err != nil {
+		return "", err
+	}
+	if out.AuthenticationResult == nil {
+		return "", fmt.Errorf("%s challenge is required", out.ChallengeName)
+	}
+	if secret == "" {
+		assoc, err := c.client.AssociateSoftwareToken(ctx, &cognito.AssociateSoftwareTokenInput{
+			AccessToken: out.AuthenticationResult.AccessToken,
+		})
+		if err != nil {
+			return "", err
+		}
+		secret = aws.ToString(assoc.SecretCode)
+		code, err := totp.Code(secret, time.Now())
+		if err != nil {
+			return "", err
+		}
+		verified, err := c.client.VerifySoftwareToken(ctx, &cognito.VerifySoftwareTokenInput{
+			AccessToken:        out.AuthenticationResult.AccessToken,
+			UserCode:           aws.String(code),
+			FriendlyDeviceName: aws.String(version.Name),
+		})
+		if err != nil {
+			return "", err
+		}
+		if verified.Status != types.VerifySoftwareTokenResponseTypeSuccess {
+			return "", fmt.Errorf("failed to verify software token: %s", verified.Status)
+		}
+	}
+	if err := c.setSoftwareTokenMFA(ctx, user.Username, true); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// DisableSoftwareTokenMFA disables software token MFA of the user.
+func (c *Client) DisableSoftwareTokenMFA(ctx context.Context, username string) error {
+	return c.setSoftwareTokenMFA(ctx, username, false)
+}
+
+// UserMFA returns the MFA preference of the user. It returns nil if no MFA is enabled.
+func (c *Client) UserMFA(ctx context.Context, username string) (*MFA, error) {
+	out, err := c.client.AdminGetUser(ctx, &cognito.AdminGetUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.PreferredMfaSetting == nil && len(out.UserMFASettingList) == 0 {
+		return nil, nil
+	}
+	return &MFA{
+		Preferred: aws.ToString(out.PreferredMfaSetting),
+		Enabled:   out.UserMFASettingList,
+	}, nil
+}
+
+// Name returns the name of the user pool.
+func (c *Client) Name(ctx context.Context) (string, error) {
+	out, err := c.client.DescribeUserPool(ctx, &cognito.DescribeUserPoolInput{
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.UserPool.Name), nil
+}
+
+func (c *Client) setSoftwareTokenMFA(ctx context.Context, username string, enabled bool) error {
+	_, err := c.client.AdminSetUserMFAPreference(ctx, &cognito.AdminSetUserMFAPreferenceInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+		SoftwareTokenMfaSettings: &types.SoftwareTokenMfaSettingsType{
+			Enabled:      enabled,
+			PreferredMfa: enabled,
+		},
+	})
+	return err
+}
@@ -0,0 +1,159 @@
+package userpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+type PlanAction string
+
+const (
+	PlanActionCreate PlanAction = "create"
+	PlanActionUpdate PlanAction = "update"
+	PlanActionNoop   PlanAction = "no-op"
+)
+
+// Change is a difference between the current user and the desired user.
+// Old is nil when the value is not set yet.
+type Change struct {
+	Name string
+	Old  *string
+	New  string
+}
+
+// UserPlan is the result of comparing a user in the user pool with the desired user.
+type UserPlan struct {
+	Username string
+	Action   PlanAction
+	Changes  []Change
+}
+
+// PlanUser compares the user with the current user in the user pool and returns changes that ApplyUser would make.
+// Passwords cannot be read from the user pool, so only the resulting user status is compared.
+func (c *Client) PlanUser(ctx context.Context, user User, opts ...ApplyUserOptionFunc) (*UserPlan, error) {
+	if user.Username == "" {
+		return nil, errors.New("username is required")
+	}
+	var opt ApplyUserOption
+	for _, o := range opts {
+		if err := o(&opt); err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := c.getUser(ctx, user.Username)
+	if err != nil {
+		return nil, err
+	}
+	var currentGroups []string
+	if current != nil && user.Groups != nil {
+		currentGroups, err = c.userGroups(ctx, user.Username)
+		if err != nil {
+			return nil, err
+		}
+	}
+	plan, _ := diffUser(user, opt, current, currentGroups)
+	return plan, nil
+}
+
+// diffUser returns the changes from the current user to the desired user, and the resulting user status.
+// current is nil when the user does not exist.
+func diffUser(user User, opt ApplyUserOption, current *cognito.AdminGetUserOutput, currentGroups []string) (*UserPlan, types.UserStatusType) {
+	plan := &UserPlan{
+		Username: user.Username,
+		Action:   PlanActionNoop,
+	}
+	if current == nil {
+		plan.Action = PlanActionCreate
+		current = &cognito.AdminGetUserOutput{
+			Enabled:    true,
+			UserStatus: types.UserStatusTypeForceChangePassword,
+		}
+	}
+
+	currentAttrs := map[string]string{}
+	for _, attr := range current.UserAttributes {
+		currentAttrs[aws.ToString(attr.Name)] = aws.ToString(attr.Value)
+	}
+	var names []string
+	for name := range user.Attributes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		v := attributeValue(user.Attributes[name])
+		old, ok := currentAttrs[name]
+		switch {
+		case !ok:
+			plan.Changes = append(plan.Changes, Change{Name: name, New: v})
+		case old != v:
+			plan.Changes = append(plan.Changes, Change{Name: name, Old: aws.String(old), New: v})
+		}
+	}
+
+	if user.Groups != nil {
+		currentGroups := slices.Clone(currentGroups)
+		groups := slices.Clone(user.Groups)
+		if !opt.ReconcileGroups {
+			for _, g := range currentGroups {
+				if !slices.Contains(groups, g) {
+					groups = append(groups, g)
+				}
+			}
+		}
+		slices.Sort(groups)
+		slices.Sort(currentGroups)
+		switch {
+		case plan.Action == PlanActionCreate:
+			if len(groups) > 0 {
+				plan.Changes = append(plan.Changes, Change{Name: "groups", New: strings.Join(groups, ",")})
+			}
+		case !slices.Equal(groups, currentGroups):
+			plan.Changes = append(plan.Changes, Change{Name: "groups", Old: aws.String(strings.Join(currentGroups, ",")), New: strings.Join(groups, ",")})
+		}
+	}
+
+	if user.Enabled != nil {
+		enabled := strconv.FormatBool(*user.Enabled)
+		switch {
+		case plan.Action == PlanActionCreate:
+			plan.Changes = append(plan.Changes, Change{Name: "enabled", New: enabled})
+		case *user.Enabled != current.Enabled:
+			plan.Changes = append(plan.Changes, Change{Name: "enabled", Old: aws.String(strconv.FormatBool(current.Enabled)), New: enabled})
+		}
+	}
+
+	status := current.UserStatus
+	switch {
+	case opt.SendPasswordResetCode:
+		status = types.UserStatusTypeResetRequired
+	case opt.Password != "" || opt.RandomPassword || user.Password != "":
+		if opt.PermanentPassword {
+			status = types.UserStatusTypeConfirmed
+		} else {
+			status = types.UserStatusTypeForceChangePassword
+		}
+	}
+	if plan.Action == PlanActionCreate {
+		plan.Changes = append(plan.Changes, Change{Name: "status", New: string(status)})
+	} else if status != current.UserStatus {
+		plan.Changes = append(plan.Changes, Change{Name: "status", Old: aws.String(string(current.UserStatus)), New: string(status)})
+	}
+
+	if plan.Action == PlanActionNoop && len(plan.Changes) > 0 {
+		plan.Action = PlanActionUpdate
+	}
+	return plan, status
+}
+
+func attributeValue(v any) string {
+	return fmt.Sprintf("%v", v)
+}
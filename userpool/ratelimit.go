@@ -0,0 +1,243 @@
+package userpool
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"golang.org/x/time/rate"
+)
+
+// quotaCategory is the category of the request rate quotas of Amazon Cognito user pools.
+type quotaCategory string
+
+const (
+	quotaUserAuthentication  quotaCategory = "UserAuthentication"
+	quotaUserCreation        quotaCategory = "UserCreation"
+	quotaUserAccountRecovery quotaCategory = "UserAccountRecovery"
+	quotaUserRead            quotaCategory = "UserRead"
+	quotaUserUpdate          quotaCategory = "UserUpdate"
+	quotaUserList            quotaCategory = "UserList"
+	quotaUserResourceRead    quotaCategory = "UserResourceRead"
+	quotaUserResourceUpdate  quotaCategory = "UserResourceUpdate"
+)
+
+// defaultQuotas are the default requests per second of the quota categories.
+var defaultQuotas = map[quotaCategory]float64{
+	quotaUserAuthentication:  120,
+	quotaUserCreation:        50,
+	quotaUserAccountRecovery: 30,
+	quotaUserRead:            120,
+	quotaUserUpdate:          25,
+	quotaUserList:            30,
+	quotaUserResourceRead:    50,
+	quotaUserResourceUpdate:  25,
+}
+
+const (
+	// minRate is the lowest rate the adaptive back-off slows down to.
+	minRate = 1
+	// maxThrottleRetries is the number of retries of a throttled request.
+	maxThrottleRetries = 10
+	maxBackoff         = 20 * time.Second
+)
+
+var _ API = (*rateLimitedAPI)(nil)
+
+// rateLimitedAPI limits the request rate of each quota category with a token bucket.
+// When a request is throttled with TooManyRequestsException, the rate of the category is halved and the request is retried with back-off.
+// The rate is recovered gradually while requests succeed.
+type rateLimitedAPI struct {
+	api     API
+	buckets map[quotaCategory]*bucket
+}
+
+type bucket struct {
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	max     rate.Limit
+}
+
+// newRateLimitedAPI returns the API that limits the request rate of each quota category to rps.
+// If rps is 0, the default quotas of Amazon Cognito are used.
+func newRateLimitedAPI(api API, rps float64) *rateLimitedAPI {
+	buckets := map[quotaCategory]*bucket{}
+	for c, q := range defaultQuotas {
+		if rps > 0 {
+			q = rps
+		}
+		buckets[c] = &bucket{
+			limiter: rate.NewLimiter(rate.Limit(q), max(1, int(q))),
+			max:     rate.Limit(q),
+		}
+	}
+	return &rateLimitedAPI{api: api, buckets: buckets}
+}
+
+func (b *bucket) throttled() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limiter.SetLimit(max(b.limiter.Limit()/2, minRate))
+}
+
+func (b *bucket) succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if l := b.limiter.Limit(); l < b.max {
+		b.limiter.SetLimit(min(l+b.max/20, b.max))
+	}
+}
+
+func isThrottled(err error) bool {
+	var tmr *types.TooManyRequestsException
+	return errors.As(err, &tmr)
+}
+
+// noThrottleRetry makes the SDK not retry throttled requests, which are retried by rateLimitedAPI.
+var noThrottleRetry = retryableFunc(func(err error) aws.Ternary {
+	if isThrottled(err) {
+		return aws.FalseTernary
+	}
+	return aws.UnknownTernary
+})
+
+type retryableFunc func(error) aws.Ternary
+
+func (f retryableFunc) IsErrorRetryable(err error) aws.Ternary {
+	return f(err)
+}
+
+func call[In, Out any](ctx context.Context, a *rateLimitedAPI, c quotaCategory, f func(context.Context, In, ...func(*cognito.Options)) (Out, error), in In, optFns []func(*cognito.Options)) (Out, error) {
+	b := a.buckets[c]
+	for i := 0; ; i++ {
+		if err := b.limiter.Wait(ctx); err != nil {
+			var zero Out
+			return zero, err
+		}
+		out, err := f(ctx, in, optFns...)
+		if err == nil {
+			b.succeeded()
+			return out, nil
+		}
+		if !isThrottled(err) || i >= maxThrottleRetries {
+			return out, err
+		}
+		b.throttled()
+		// exponential back-off with full jitter
+		d := min(100*time.Millisecond<<i, maxBackoff)
+		select {
+		case <-ctx.Done():
+			return out, err
+		case <-time.After(rand.N(d) + 1):
+		}
+	}
+}
+
+func (a *rateLimitedAPI) AdminGetUser(ctx context.Context, params *cognito.AdminGetUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminGetUserOutput, error) {
+	return call(ctx, a, quotaUserRead, a.api.AdminGetUser, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminCreateUser(ctx context.Context, params *cognito.AdminCreateUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminCreateUserOutput, error) {
+	return call(ctx, a, quotaUserCreation, a.api.AdminCreateUser, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminUpdateUserAttributes(ctx context.Context, params *cognito.AdminUpdateUserAttributesInput, optFns ...func(*cognito.Options)) (*cognito.AdminUpdateUserAttributesOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.AdminUpdateUserAttributes, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminSetUserPassword(ctx context.Context, params *cognito.AdminSetUserPasswordInput, optFns ...func(*cognito.Options)) (*cognito.AdminSetUserPasswordOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.AdminSetUserPassword, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminAddUserToGroup(ctx context.Context, params *cognito.AdminAddUserToGroupInput, optFns ...func(*cognito.Options)) (*cognito.AdminAddUserToGroupOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.AdminAddUserToGroup, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminRemoveUserFromGroup(ctx context.Context, params *cognito.AdminRemoveUserFromGroupInput, optFns ...func(*cognito.Options)) (*cognito.AdminRemoveUserFromGroupOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.AdminRemoveUserFromGroup, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminListGroupsForUser(ctx context.Context, params *cognito.AdminListGroupsForUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminListGroupsForUserOutput, error) {
+	return call(ctx, a, quotaUserRead, a.api.AdminListGroupsForUser, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminDeleteUser(ctx context.Context, params *cognito.AdminDeleteUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminDeleteUserOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.AdminDeleteUser, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminDisableUser(ctx context.Context, params *cognito.AdminDisableUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminDisableUserOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.AdminDisableUser, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminEnableUser(ctx context.Context, params *cognito.AdminEnableUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminEnableUserOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.AdminEnableUser, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminSetUserMFAPreference(ctx context.Context, params *cognito.AdminSetUserMFAPreferenceInput, optFns ...func(*cognito.Options)) (*cognito.AdminSetUserMFAPreferenceOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.AdminSetUserMFAPreference, params, optFns)
+}
+
+func (a *rateLimitedAPI) AdminResetUserPassword(ctx context.Context, params *cognito.AdminResetUserPasswordInput, optFns ...func(*cognito.Options)) (*cognito.AdminResetUserPasswordOutput, error) {
+	return call(ctx, a, quotaUserAccountRecovery, a.api.AdminResetUserPassword, params, optFns)
+}
+
+func (a *rateLimitedAPI) GetGroup(ctx context.Context, params *cognito.GetGroupInput, optFns ...func(*cognito.Options)) (*cognito.GetGroupOutput, error) {
+	return call(ctx, a, quotaUserResourceRead, a.api.GetGroup, params, optFns)
+}
+
+func (a *rateLimitedAPI) CreateGroup(ctx context.Context, params *cognito.CreateGroupInput, optFns ...func(*cognito.Options)) (*cognito.CreateGroupOutput, error) {
+	return call(ctx, a, quotaUserResourceUpdate, a.api.CreateGroup, params, optFns)
+}
+
+func (a *rateLimitedAPI) UpdateGroup(ctx context.Context, params *cognito.UpdateGroupInput, optFns ...func(*cognito.Options)) (*cognito.UpdateGroupOutput, error) {
+	return call(ctx, a, quotaUserResourceUpdate, a.api.UpdateGroup, params, optFns)
+}
+
+func (a *rateLimitedAPI) DeleteGroup(ctx context.Context, params *cognito.DeleteGroupInput, optFns ...func(*cognito.Options)) (*cognito.DeleteGroupOutput, error) {
+	return call(ctx, a, quotaUserResourceUpdate, a.api.DeleteGroup, params, optFns)
+}
+
+func (a *rateLimitedAPI) ListGroups(ctx context.Context, params *cognito.ListGroupsInput, optFns ...func(*cognito.Options)) (*cognito.ListGroupsOutput, error) {
+	return call(ctx, a, quotaUserResourceRead, a.api.ListGroups, params, optFns)
+}
+
+func (a *rateLimitedAPI) DescribeUserPool(ctx context.Context, params *cognito.DescribeUserPoolInput, optFns ...func(*cognito.Options)) (*cognito.DescribeUserPoolOutput, error) {
+	return call(ctx, a, quotaUserResourceRead, a.api.DescribeUserPool, params, optFns)
+}
+
+func (a *rateLimitedAPI) ListUsers(ctx context.Context, params *cognito.ListUsersInput, optFns ...func(*cognito.Options)) (*cognito.ListUsersOutput, error) {
+	return call(ctx, a, quotaUserList, a.api.ListUsers, params, optFns)
+}
+
+func (a *rateLimitedAPI) ListUserPools(ctx context.Context, params *cognito.ListUserPoolsInput, optFns ...func(*cognito.Options)) (*cognito.ListUserPoolsOutput, error) {
+	return call(ctx, a, quotaUserResourceRead, a.api.ListUserPools, params, optFns)
+}
+
+func (a *rateLimitedAPI) ListUserPoolClients(ctx context.Context, params *cognito.ListUserPoolClientsInput, optFns ...func(*cognito.Options)) (*cognito.ListUserPoolClientsOutput, error) {
+	return call(ctx, a, quotaUserResourceRead, a.api.ListUserPoolClients, params, optFns)
+}
+
+func (a *rateLimitedAPI) DescribeUserPoolClient(ctx context.Context, params *cognito.DescribeUserPoolClientInput, optFns ...func(*cognito.Options)) (*cognito.DescribeUserPoolClientOutput, error) {
+	return call(ctx, a, quotaUserResourceRead, a.api.DescribeUserPoolClient, params, optFns)
+}
+
+func (a *rateLimitedAPI) InitiateAuth(ctx context.Context, params *cognito.InitiateAuthInput, optFns ...func(*cognito.Options)) (*cognito.InitiateAuthOutput, error) {
+	return call(ctx, a, quotaUserAuthentication, a.api.InitiateAuth, params, optFns)
+}
+
+func (a *rateLimitedAPI) RespondToAuthChallenge(ctx context.Context, params *cognito.RespondToAuthChallengeInput, optFns ...func(*cognito.Options)) (*cognito.RespondToAuthChallengeOutput, error) {
+	return call(ctx, a, quotaUserAuthentication, a.api.RespondToAuthChallenge, params, optFns)
+}
+
+func (a *rateLimitedAPI) AssociateSoftwareToken(ctx context.Context, params *cognito.AssociateSoftwareTokenInput, optFns ...func(*cognito.Options)) (*cognito.AssociateSoftwareTokenOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.AssociateSoftwareToken, params, optFns)
+}
+
+func (a *rateLimitedAPI) VerifySoftwareToken(ctx context.Context, params *cognito.VerifySoftwareTokenInput, optFns ...func(*cognito.Options)) (*cognito.VerifySoftwareTokenOutput, error) {
+	return call(ctx, a, quotaUserUpdate, a.api.VerifySoftwareToken, params, optFns)
+}
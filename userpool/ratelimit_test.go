@@ -0,0 +1,155 @@
+package userpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"golang.org/x/time/rate"
+)
+
+// throttlingAPI throttles the first throttles requests of AdminGetUser and then returns err.
+type throttlingAPI struct {
+	API
+	throttles int32
+	err       error
+	calls     atomic.Int32
+}
+
+func (a *throttlingAPI) AdminGetUser(ctx context.Context, params *cognito.AdminGetUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminGetUserOutput, error) {
+	if a.calls.Add(1) <= a.throttles {
+		return nil, &types.TooManyRequestsException{Message: aws.String("Too many requests")}
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &cognito.AdminGetUserOutput{Username: params.Username}, nil
+}
+
+func TestNewRateLimitedAPI(t *testing.T) {
+	tests := []struct {
+		name string
+		rps  float64
+		want map[quotaCategory]rate.Limit
+	}{
+		{"default quotas", 0, map[quotaCategory]rate.Limit{quotaUserCreation: 50, quotaUserUpdate: 25, quotaUserRead: 120, quotaUserResourceUpdate: 25}},
+		{"rate", 5, map[quotaCategory]rate.Limit{quotaUserCreation: 5, quotaUserUpdate: 5, quotaUserRead: 5, quotaUserResourceUpdate: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newRateLimitedAPI(&throttlingAPI{}, tt.rps)
+			if len(a.buckets) != len(defaultQuotas) {
+				t.Errorf("got %d buckets, want %d", len(a.buckets), len(defaultQuotas))
+			}
+			for c, want := range tt.want {
+				if got := a.buckets[c].limiter.Limit(); got != want {
+					t.Errorf("%s: got %v, want %v", c, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBucketAdaptiveRate(t *testing.T) {
+	b := &bucket{limiter: rate.NewLimiter(20, 20), max: 20}
+	steps := []struct {
+		throttled bool
+		want      rate.Limit
+	}{
+		{true, 10},
+		{true, 5},
+		{false, 6},
+		{true, 3},
+		{true, 1.5},
+		{true, minRate},
+		{true, minRate},
+		{false, 2},
+	}
+	for i, s := range steps {
+		if s.throttled {
+			b.throttled()
+		} else {
+			b.succeeded()
+		}
+		if got := b.limiter.Limit(); got != s.want {
+			t.Errorf("step %d: got %v, want %v", i, got, s.want)
+		}
+	}
+	for range 100 {
+		b.succeeded()
+	}
+	if got := b.limiter.Limit(); got != b.max {
+		t.Errorf("got %v, want recovered to %v", got, b.max)
+	}
+}
+
+func TestRateLimitedAPIRetry(t *testing.T) {
+	errOther := errors.New("other error")
+	tests := []struct {
+		name      string
+		throttles int32
+		err       error
+		wantErr   error
+		wantCalls int32
+		wantRate  rate.Limit
+	}{
+		{"no throttle", 0, nil, nil, 1, 120},
+		{"retry throttled requests", 2, nil, nil, 3, 30 + 6},
+		{"other errors are not retried", 0, errOther, errOther, 1, 120},
+		{"other error after throttle", 1, errOther, errOther, 2, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &throttlingAPI{throttles: tt.throttles, err: tt.err}
+			a := newRateLimitedAPI(api, 0)
+			out, err := a.AdminGetUser(context.Background(), &cognito.AdminGetUserInput{Username: aws.String("alice")})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && aws.ToString(out.Username) != "alice" {
+				t.Errorf("got %+v", out)
+			}
+			if got := api.calls.Load(); got != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", got, tt.wantCalls)
+			}
+			if got := a.buckets[quotaUserRead].limiter.Limit(); got != tt.wantRate {
+				t.Errorf("got rate %v, want %v", got, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestRateLimitedAPICanceled(t *testing.T) {
+	api := &throttlingAPI{throttles: maxThrottleRetries + 1}
+	a := newRateLimitedAPI(api, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := a.AdminGetUser(ctx, &cognito.AdminGetUserInput{Username: aws.String("alice")})
+	if !isThrottled(err) && !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want throttled or deadline exceeded", err)
+	}
+	if got := api.calls.Load(); got > maxThrottleRetries {
+		t.Errorf("got %d calls, want canceled before all retries", got)
+	}
+}
+
+func TestRateLimitedAPILimit(t *testing.T) {
+	const rps = 20
+	api := &throttlingAPI{}
+	a := newRateLimitedAPI(api, rps)
+	start := time.Now()
+	// the burst is rps, and the rest are limited to rps
+	for range rps + rps/2 {
+		if _, err := a.AdminGetUser(context.Background(), &cognito.AdminGetUserInput{Username: aws.String("alice")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("got %s, want at least 400ms", elapsed)
+	}
+}
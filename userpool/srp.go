@@ -0,0 +1,104 @@
+package userpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/internal/srp"
+)
+
+// loginWithSRP logs in with USER_SRP_AUTH and responds to the PASSWORD_VERIFIER challenge.
+func (c *Client) loginWithSRP(ctx context.Context, ac *appClient, user User) (*cognito.InitiateAuthOutput, error) {
+	a, err := srp.RandomInt()
+	if err != nil {
+		return nil, err
+	}
+	A := new(big.Int).Exp(srp.G, a, srp.N)
+	if new(big.Int).Mod(A, srp.N).Sign() == 0 {
+		return nil, errors.New("invalid SRP_A")
+	}
+
+	params := map[string]string{
+		"USERNAME": user.Username,
+		"SRP_A":    A.Text(16),
+	}
+	if ac.secret != "" {
+		params["SECRET_HASH"] = secretHash(ac.id, ac.secret, user.Username)
+	}
+	out, err := c.client.InitiateAuth(ctx, &cognito.InitiateAuthInput{
+		ClientId:       aws.String(ac.id),
+		AuthFlow:       types.AuthFlowTypeUserSrpAuth,
+		AuthParameters: params,
+		ClientMetadata: user.ClientMetadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.ChallengeName != types.ChallengeNameTypePasswordVerifier {
+		return out, nil
+	}
+
+	cp := out.ChallengeParameters
+	userID := cp["USER_ID_FOR_SRP"]
+	B, ok := new(big.Int).SetString(cp["SRP_B"], 16)
+	if !ok || new(big.Int).Mod(B, srp.N).Sign() == 0 {
+		return nil, errors.New("invalid SRP_B")
+	}
+	salt, ok := new(big.Int).SetString(cp["SALT"], 16)
+	if !ok {
+		return nil, errors.New("invalid SALT")
+	}
+	u := srp.U(A, B)
+	if u.Sign() == 0 {
+		return nil, errors.New("invalid SRP scrambling parameter")
+	}
+	poolName := srp.PoolName(c.userPoolID)
+	x := srp.X(salt, poolName, userID, user.Password)
+	// S = (B - k * g^x) ^ (a + u * x) % N
+	base := new(big.Int).Sub(B, new(big.Int).Mul(srp.K, new(big.Int).Exp(srp.G, x, srp.N)))
+	base.Mod(base, srp.N)
+	exp := new(big.Int).Add(a, new(big.Int).Mul(u, x))
+	S := new(big.Int).Exp(base, exp, srp.N)
+
+	timestamp := srp.Timestamp(time.Now())
+	signature, err := srp.Signature(srp.DerivedKey(S, u), poolName, userID, cp["SECRET_BLOCK"], timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SECRET_BLOCK: %w", err)
+	}
+	responses := map[string]string{
+		"USERNAME":                    userID,
+		"PASSWORD_CLAIM_SECRET_BLOCK": cp["SECRET_BLOCK"],
+		"PASSWORD_CLAIM_SIGNATURE":    signature,
+		"TIMESTAMP":                   timestamp,
+	}
+	if ac.secret != "" {
+		responses["SECRET_HASH"] = secretHash(ac.id, ac.secret, userID)
+	}
+	return c.respondToAuthChallenge(ctx, ac, user, out.ChallengeName, out.Session, responses)
+}
+
+func (c *Client) respondToAuthChallenge(ctx context.Context, ac *appClient, user User, name types.ChallengeNameType, session *string, responses map[string]string) (*cognito.InitiateAuthOutput, error) {
+	out, err := c.client.RespondToAuthChallenge(ctx, &cognito.RespondToAuthChallengeInput{
+		ClientId:           aws.String(ac.id),
+		ChallengeName:      name,
+		ChallengeResponses: responses,
+		Session:            session,
+		ClientMetadata:     user.ClientMetadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cognito.InitiateAuthOutput{
+		AuthenticationResult: out.AuthenticationResult,
+		ChallengeName:        out.ChallengeName,
+		ChallengeParameters:  out.ChallengeParameters,
+		Session:              out.Session,
+		ResultMetadata:       out.ResultMetadata,
+	}, nil
+}
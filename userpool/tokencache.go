@@ -0,0 +1,125 @@
+package userpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+// ErrTokenCacheMiss is returned by TokenCache.Get when no tokens are cached for the key.
+var ErrTokenCacheMiss = errors.New("token cache miss")
+
+// TokenCache stores tokens obtained by LoginAs.
+type TokenCache interface {
+	// Get returns the cached tokens. It returns ErrTokenCacheMiss if no tokens are cached for the key.
+	Get(ctx context.Context, key TokenCacheKey) (*CachedToken, error)
+	Set(ctx context.Context, token *CachedToken) error
+	Delete(ctx context.Context, key TokenCacheKey) error
+	List(ctx context.Context) ([]*CachedToken, error)
+}
+
+// TokenCacheLocker is implemented by TokenCache that can lock the key across processes.
+// LoginAs holds the lock while it reads the cache and authenticates,
+// so that only one process authenticates per key while the others wait and read the fresh tokens.
+type TokenCacheLocker interface {
+	Lock(ctx context.Context, key TokenCacheKey) (unlock func() error, err error)
+}
+
+// TokenCacheKey identifies the cached tokens.
+type TokenCacheKey struct {
+	UserPoolID string `json:"user_pool_id"`
+	ClientID   string `json:"client_id"`
+	Username   string `json:"username"`
+	AuthFlow   string `json:"auth_flow"`
+}
+
+// CachedToken is the tokens stored in TokenCache.
+type CachedToken struct {
+	TokenCacheKey
+	ExpiredAt int64 `json:"expired_at"`
+	Auth      *cognito.InitiateAuthOutput
+}
+
+// NewCachedToken returns the tokens to be cached. They expire 1 minute before the tokens do.
+func NewCachedToken(key TokenCacheKey, out *cognito.InitiateAuthOutput) *CachedToken {
+	return &CachedToken{
+		TokenCacheKey: key,
+		ExpiredAt:     int64(out.AuthenticationResult.ExpiresIn) + time.Now().Unix() - 60, // 1 min before
+		Auth:          out,
+	}
+}
+
+// Expired reports whether the ID and access tokens are expired.
+func (t *CachedToken) Expired() bool {
+	return t.ExpiredAt < time.Now().Unix()
+}
+
+// Refreshable reports whether the tokens can be renewed with the refresh token.
+func (t *CachedToken) Refreshable() bool {
+	return t.Auth != nil && t.Auth.AuthenticationResult != nil && t.Auth.AuthenticationResult.RefreshToken != nil
+}
+
+func (c *Client) loginAsWithCache(ctx context.Context, ac *appClient, flow types.AuthFlowType, user User, opt LoginAsOption, key TokenCacheKey) (_ *cognito.InitiateAuthOutput, err error) {
+	if l, ok := opt.TokenCache.(TokenCacheLocker); ok {
+		unlock, err := l.Lock(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			err = errors.Join(err, unlock())
+		}()
+	}
+	out, err := c.tokenFromCache(ctx, ac, opt.TokenCache, key)
+	if err == nil {
+		return out, nil
+	}
+	out, err = c.loginAs(ctx, ac, flow, user, opt)
+	if err != nil {
+		return nil, err
+	}
+	if out.AuthenticationResult != nil {
+		if err := opt.TokenCache.Set(ctx, NewCachedToken(key, out)); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// tokenFromCache returns the cached tokens.
+// Expired tokens are renewed with the cached refresh token, and the cache is deleted when they cannot be renewed.
+func (c *Client) tokenFromCache(ctx context.Context, ac *appClient, tc TokenCache, key TokenCacheKey) (*cognito.InitiateAuthOutput, error) {
+	t, err := tc.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !t.Expired() {
+		return t.Auth, nil
+	}
+	if !t.Refreshable() {
+		if err := tc.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("token expired")
+	}
+	refreshToken := t.Auth.AuthenticationResult.RefreshToken
+	out, err := c.refreshToken(ctx, ac, key.Username, *refreshToken)
+	if err != nil {
+		// the refresh token is expired or revoked
+		if err := tc.Delete(ctx, key); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	if out.AuthenticationResult.RefreshToken == nil {
+		// keep the refresh token for the next renewal
+		out.AuthenticationResult.RefreshToken = refreshToken
+	}
+	if err := tc.Set(ctx, NewCachedToken(key, out)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,154 @@
+package tokencache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"sync"
+)
+
+const (
+	// encryptedVersion is the format of files whose key is derived from a passphrase by PBKDF2.
+	encryptedVersion = 1
+	// keyEncryptedVersion is the format of files whose key is derived from a key by HKDF.
+	keyEncryptedVersion = 2
+	saltSize            = 16
+	keySize             = 32
+	iterations          = 600000
+	// minKeySize is the minimum size of a key to be derived by HKDF instead of PBKDF2.
+	minKeySize = 32
+	hkdfInfo   = "coglet token cache"
+)
+
+// NewEncryptedFile returns a TokenCache that stores tokens as files in dir encrypted with AES-256-GCM.
+// The key is derived from the passphrase by PBKDF2 with a random salt.
+func NewEncryptedFile(dir string, passphrase []byte) *File {
+	return &File{
+		dir:   dir,
+		ext:   ".enc",
+		codec: newAEADCodec(passphrase, encryptedVersion),
+	}
+}
+
+// NewEncryptedFileWithKey returns a TokenCache like NewEncryptedFile, for a random key such as the content of a key file.
+// The key is derived from it by HKDF, which is much cheaper than PBKDF2 for every file.
+// A key shorter than 32 bytes is treated as a passphrase.
+func NewEncryptedFileWithKey(dir string, key []byte) *File {
+	return &File{
+		dir:   dir,
+		ext:   ".enc",
+		codec: newAEADCodec(key, keyVersion(key)),
+	}
+}
+
+func keyVersion(key []byte) byte {
+	if len(key) < minKeySize {
+		return encryptedVersion
+	}
+	return keyEncryptedVersion
+}
+
+func newAEADCodec(secret []byte, version byte) *aeadCodec {
+	return &aeadCodec{
+		secret:  secret,
+		version: version,
+		keys:    map[string][]byte{},
+	}
+}
+
+// aeadCodec encrypts the cached token as version || salt || nonce || ciphertext.
+// The version tells how the key is derived, so files written in either version can be read.
+// The ID of the cached token is used as additional data so that files cannot be swapped.
+type aeadCodec struct {
+	secret []byte
+	// version of the files to write
+	version byte
+	mu      sync.Mutex
+	// derived keys by version and salt, to avoid deriving the key for every file
+	keys map[string][]byte
+}
+
+func (c *aeadCodec) encode(id string, b []byte) ([]byte, error) {
+	salt, err := c.salt()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.aead(c.version, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{c.version}, salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, b, []byte(id)), nil
+}
+
+func (c *aeadCodec) decode(id string, b []byte) ([]byte, error) {
+	if len(b) < 1+saltSize || (b[0] != encryptedVersion && b[0] != keyEncryptedVersion) {
+		return nil, errors.New("unsupported format")
+	}
+	salt := b[1 : 1+saltSize]
+	aead, err := c.aead(b[0], salt)
+	if err != nil {
+		return nil, err
+	}
+	b = b[1+saltSize:]
+	if len(b) < aead.NonceSize() {
+		return nil, errors.New("unsupported format")
+	}
+	plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, errors.New("failed to decrypt. the passphrase or the key file may be wrong")
+	}
+	return plain, nil
+}
+
+// salt returns a salt whose key has already been derived for the version to write, or a new random salt.
+func (c *aeadCodec) salt() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.keys {
+		if k[0] == c.version {
+			return []byte(k[1:]), nil
+		}
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func (c *aeadCodec) aead(version byte, salt []byte) (cipher.AEAD, error) {
+	k := string(version) + string(salt)
+	c.mu.Lock()
+	key, ok := c.keys[k]
+	c.mu.Unlock()
+	if !ok {
+		var err error
+		switch version {
+		case keyEncryptedVersion:
+			key, err = hkdf.Key(sha256.New, c.secret, salt, hkdfInfo, keySize)
+		default:
+			key, err = pbkdf2.Key(sha256.New, string(c.secret), salt, iterations, keySize)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.keys[k] = key
+		c.mu.Unlock()
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,146 @@
+package tokencache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/k1LoW/coglet/userpool"
+)
+
+func TestEncryptedFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	want := newCachedToken("alice")
+	f := NewEncryptedFile(dir, []byte("passphrase"))
+	if err := f.Set(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, ID(want.TokenCacheKey)+".enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("id-token-of-alice")) {
+		t.Error("token is not encrypted")
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		wantErr    bool
+	}{
+		{"same passphrase", "passphrase", false},
+		{"wrong passphrase", "wrong", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a new instance does not share the derived keys
+			got, err := NewEncryptedFile(dir, []byte(tt.passphrase)).Get(ctx, want.TokenCacheKey)
+			if tt.wantErr {
+				if err == nil || errors.Is(err, userpool.ErrTokenCacheMiss) {
+					t.Errorf("got %v, want decryption error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.TokenCacheKey != want.TokenCacheKey || got.ExpiredAt != want.ExpiredAt {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+			if aws.ToString(got.Auth.AuthenticationResult.IdToken) != "id-token-of-alice" {
+				t.Errorf("got %+v", got.Auth.AuthenticationResult)
+			}
+		})
+	}
+}
+
+func TestEncryptedFileMiss(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	tk := newCachedToken("alice")
+	// plain tokens are not read by the encrypted cache
+	if err := NewFile(dir).Set(ctx, tk); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEncryptedFile(dir, []byte("passphrase")).Get(ctx, tk.TokenCacheKey); !errors.Is(err, userpool.ErrTokenCacheMiss) {
+		t.Errorf("got %v, want ErrTokenCacheMiss", err)
+	}
+}
+
+func TestEncryptedFileSwapped(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f := NewEncryptedFile(dir, []byte("passphrase"))
+	alice := newCachedToken("alice")
+	bob := newCachedToken("bob")
+	for _, tk := range []*userpool.CachedToken{alice, bob} {
+		if err := f.Set(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the ID is authenticated, so the token of bob cannot be used as the token of alice
+	if err := os.Rename(filepath.Join(dir, ID(bob.TokenCacheKey)+".enc"), filepath.Join(dir, ID(alice.TokenCacheKey)+".enc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Get(ctx, alice.TokenCacheKey); err == nil {
+		t.Error("want error for swapped file")
+	}
+}
+
+func TestEncryptedFileUnsupportedFormat(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	tk := newCachedToken("alice")
+	if err := os.WriteFile(filepath.Join(dir, ID(tk.TokenCacheKey)+".enc"), []byte{2, 0, 1}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEncryptedFile(dir, []byte("passphrase")).Get(ctx, tk.TokenCacheKey); err == nil {
+		t.Error("want error for unsupported format")
+	}
+}
+
+func TestEncryptedFileWithKey(t *testing.T) {
+	key := bytes.Repeat([]byte("k"), 32)
+	tests := []struct {
+		name        string
+		write       func(dir string, key []byte) *File
+		key         []byte
+		wantVersion byte
+	}{
+		{"key", NewEncryptedFileWithKey, key, keyEncryptedVersion},
+		{"short key is a passphrase", NewEncryptedFileWithKey, []byte("short"), encryptedVersion},
+		{"written with the key as a passphrase", NewEncryptedFile, key, encryptedVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			want := newCachedToken("alice")
+			if err := tt.write(dir, tt.key).Set(ctx, want); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(filepath.Join(dir, ID(want.TokenCacheKey)+".enc"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b[0] != tt.wantVersion {
+				t.Errorf("version: got %d, want %d", b[0], tt.wantVersion)
+			}
+			got, err := NewEncryptedFileWithKey(dir, tt.key).Get(ctx, want.TokenCacheKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aws.ToString(got.Auth.AuthenticationResult.IdToken) != "id-token-of-alice" {
+				t.Errorf("got %+v", got.Auth.AuthenticationResult)
+			}
+			if _, err := NewEncryptedFileWithKey(dir, bytes.Repeat([]byte("x"), 32)).Get(ctx, want.TokenCacheKey); err == nil {
+				t.Error("want error with another key")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package tokencache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Secrets stores secrets such as TOTP secrets as files in the directory.
+type Secrets struct {
+	dir   string
+	ext   string
+	codec codec
+}
+
+// NewSecrets returns Secrets that stores secrets as plain text files in dir.
+func NewSecrets(dir string) *Secrets {
+	return &Secrets{
+		dir:   dir,
+		codec: plain{},
+	}
+}
+
+// NewEncryptedSecrets returns Secrets that stores secrets in dir encrypted in the same way as NewEncryptedFile.
+// Plain text secrets stored by NewSecrets are encrypted when they are read.
+func NewEncryptedSecrets(dir string, passphrase []byte) *Secrets {
+	return &Secrets{
+		dir:   dir,
+		ext:   ".enc",
+		codec: newAEADCodec(passphrase, encryptedVersion),
+	}
+}
+
+// NewEncryptedSecretsWithKey returns Secrets like NewEncryptedSecrets, with the key derived in the same way as NewEncryptedFileWithKey.
+func NewEncryptedSecretsWithKey(dir string, key []byte) *Secrets {
+	return &Secrets{
+		dir:   dir,
+		ext:   ".enc",
+		codec: newAEADCodec(key, keyVersion(key)),
+	}
+}
+
+// Get returns the secret of the ID. It returns an empty string if no secret is stored.
+func (s *Secrets) Get(id string) (string, error) {
+	b, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if s.ext == "" {
+			return "", nil
+		}
+		return s.migrate(id)
+	}
+	b, err = s.codec.decode(id, b)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Set stores the secret of the ID.
+func (s *Secrets) Set(id, secret string) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	b, err := s.codec.encode(id, []byte(secret))
+	if err != nil {
+		return err
+	}
+	return writeFile(s.path(id), b)
+}
+
+// migrate encrypts the plain text secret of the ID and removes the plain text file.
+func (s *Secrets) migrate(id string) (string, error) {
+	legacy := filepath.Join(s.dir, id)
+	b, err := os.ReadFile(legacy)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	secret := strings.TrimSpace(string(b))
+	if err := s.Set(id, secret); err != nil {
+		return "", err
+	}
+	if err := os.Remove(legacy); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (s *Secrets) path(id string) string {
+	return filepath.Join(s.dir, id+s.ext)
+}
@@ -0,0 +1,72 @@
+package tokencache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets func(dir string) *Secrets
+		file    string
+	}{
+		{"plain", NewSecrets, "pool_alice"},
+		{"encrypted", func(dir string) *Secrets { return NewEncryptedSecrets(dir, []byte("passphrase")) }, "pool_alice.enc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := tt.secrets(dir)
+			got, err := s.Get("pool_alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "" {
+				t.Errorf("got %q, want empty", got)
+			}
+			if err := s.Set("pool_alice", "SECRET"); err != nil {
+				t.Fatal(err)
+			}
+			got, err = s.Get("pool_alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "SECRET" {
+				t.Errorf("got %q, want %q", got, "SECRET")
+			}
+			if _, err := os.Stat(filepath.Join(dir, tt.file)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestEncryptedSecretsMigrate(t *testing.T) {
+	dir := t.TempDir()
+	if err := NewSecrets(dir).Set("pool_alice", "SECRET"); err != nil {
+		t.Fatal(err)
+	}
+	s := NewEncryptedSecrets(dir, []byte("passphrase"))
+	got, err := s.Get("pool_alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "SECRET" {
+		t.Errorf("got %q, want %q", got, "SECRET")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pool_alice")); !os.IsNotExist(err) {
+		t.Errorf("plain text secret is not removed: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "pool_alice.enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) == "SECRET" {
+		t.Error("secret is not encrypted")
+	}
+	if _, err := NewEncryptedSecrets(dir, []byte("wrong")).Get("pool_alice"); err == nil {
+		t.Error("want error with wrong passphrase")
+	}
+}
@@ -0,0 +1,248 @@
+// Package tokencache provides file based implementations of userpool.TokenCache.
+package tokencache
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/k1LoW/coglet/userpool"
+)
+
+var (
+	_ userpool.TokenCache       = (*File)(nil)
+	_ userpool.TokenCacheLocker = (*File)(nil)
+)
+
+// lockRetryDelay is the interval to retry acquiring the lock held by another process.
+const lockRetryDelay = 100 * time.Millisecond
+
+// File stores each cached token as a file in the directory.
+type File struct {
+	dir   string
+	ext   string
+	codec codec
+}
+
+// codec encodes the cached token before it is written to the file.
+type codec interface {
+	encode(id string, b []byte) ([]byte, error)
+	decode(id string, b []byte) ([]byte, error)
+}
+
+type plain struct{}
+
+func (plain) encode(_ string, b []byte) ([]byte, error) { return b, nil }
+func (plain) decode(_ string, b []byte) ([]byte, error) { return b, nil }
+
+// NewFile returns a TokenCache that stores tokens as plain JSON files in dir.
+func NewFile(dir string) *File {
+	return &File{
+		dir:   dir,
+		ext:   ".json",
+		codec: plain{},
+	}
+}
+
+// ID returns the ID of the cached token, which is also its file name without the extension.
+// It is the SHA-256 hash of the key, so that different keys never share a file and any username is a valid file name.
+func ID(key userpool.TokenCacheKey) string {
+	h := sha256.New()
+	for _, v := range []string{key.UserPoolID, key.ClientID, key.Username, key.AuthFlow} {
+		// length-prefixed so that the boundaries of the fields are unambiguous
+		_, _ = fmt.Fprintf(h, "%d:%s", len(v), v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (f *File) Get(ctx context.Context, key userpool.TokenCacheKey) (*userpool.CachedToken, error) {
+	t, err := f.read(ID(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, userpool.ErrTokenCacheMiss
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetByID returns the cached token of the ID listed by List without reading the other cached tokens.
+// It returns ErrTokenCacheMiss if no tokens are cached for the ID.
+func (f *File) GetByID(ctx context.Context, id string) (*userpool.CachedToken, error) {
+	if b, err := hex.DecodeString(id); err != nil || len(b) != sha256.Size {
+		// not an ID, and must not be used as a path
+		return nil, userpool.ErrTokenCacheMiss
+	}
+	t, err := f.read(id)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, userpool.ErrTokenCacheMiss
+		}
+		return nil, err
+	}
+	if ID(t.TokenCacheKey) != id {
+		return nil, userpool.ErrTokenCacheMiss
+	}
+	return t, nil
+}
+
+func (f *File) Set(ctx context.Context, token *userpool.CachedToken) error {
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return err
+	}
+	id := ID(token.TokenCacheKey)
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	b, err = f.codec.encode(id, b)
+	if err != nil {
+		return err
+	}
+	return writeFile(f.path(id), b)
+}
+
+// writeFile writes to a temporary file and renames it so that readers never see a partially written file.
+func writeFile(name string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Lock locks the cached token of the key with a lock file, waiting until the lock held by another process is released.
+func (f *File) Lock(ctx context.Context, key userpool.TokenCacheKey) (func() error, error) {
+	if err := os.MkdirAll(f.dir, 0700); err != nil {
+		return nil, err
+	}
+	l := flock.New(f.path(ID(key)) + ".lock")
+	locked, err := l.TryLockContext(ctx, lockRetryDelay)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, fmt.Errorf("failed to lock token cache %s", ID(key))
+	}
+	return l.Unlock, nil
+}
+
+func (f *File) Delete(ctx context.Context, key userpool.TokenCacheKey) error {
+	if err := os.Remove(f.path(ID(key))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List returns the cached tokens sorted by the user pool, the app client, the username and the auth flow.
+// Cached tokens that cannot be read are skipped with a warning.
+func (f *File) List(ctx context.Context) ([]*userpool.CachedToken, error) {
+	ids, err := f.ids()
+	if err != nil {
+		return nil, err
+	}
+	var tokens []*userpool.CachedToken
+	for _, id := range ids {
+		t, err := f.read(id)
+		if err != nil {
+			slog.Warn("skip token cache", slog.String("id", id), slog.String("error", err.Error()))
+			continue
+		}
+		if ID(t.TokenCacheKey) != id {
+			// written by an older version with another ID, and never read by Get
+			slog.Warn("skip outdated token cache", slog.String("id", id))
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	slices.SortFunc(tokens, func(a, b *userpool.CachedToken) int {
+		return cmp.Or(
+			cmp.Compare(a.UserPoolID, b.UserPoolID),
+			cmp.Compare(a.ClientID, b.ClientID),
+			cmp.Compare(a.Username, b.Username),
+			cmp.Compare(a.AuthFlow, b.AuthFlow),
+		)
+	})
+	return tokens, nil
+}
+
+// Clear deletes all cached tokens by file name without reading them, and returns the number of deleted tokens.
+func (f *File) Clear(ctx context.Context) (int, error) {
+	ids, err := f.ids()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, id := range ids {
+		if err := os.Remove(f.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ids returns the IDs of the cached tokens sorted by ID.
+func (f *File) ids() ([]string, error) {
+	files, err := os.ReadDir(f.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var ids []string
+	for _, e := range files {
+		if e.IsDir() || filepath.Ext(e.Name()) != f.ext {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(e.Name(), f.ext))
+	}
+	return ids, nil
+}
+
+func (f *File) read(id string) (*userpool.CachedToken, error) {
+	b, err := os.ReadFile(f.path(id))
+	if err != nil {
+		return nil, err
+	}
+	b, err = f.codec.decode(id, b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token cache %s: %w", id, err)
+	}
+	t := &userpool.CachedToken{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("invalid token cache %s: %w", id, err)
+	}
+	return t, nil
+}
+
+func (f *File) path(id string) string {
+	return filepath.Join(f.dir, id+f.ext)
+}
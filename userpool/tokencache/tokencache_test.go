@@ -0,0 +1,218 @@
+package tokencache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/userpool"
+)
+
+func newCachedToken(username string) *userpool.CachedToken {
+	key := userpool.TokenCacheKey{
+		UserPoolID: "ap-northeast-1_abcdefgh",
+		ClientID:   "client",
+		Username:   username,
+		AuthFlow:   string(types.AuthFlowTypeUserSrpAuth),
+	}
+	return &userpool.CachedToken{
+		TokenCacheKey: key,
+		ExpiredAt:     time.Now().Add(time.Hour).Unix(),
+		Auth: &cognito.InitiateAuthOutput{
+			AuthenticationResult: &types.AuthenticationResultType{
+				IdToken:   aws.String("id-token-of-" + username),
+				ExpiresIn: 3600,
+			},
+		},
+	}
+}
+
+func TestListSkipsInvalidEntries(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f := NewFile(dir)
+	for _, u := range []string{"alice", "bob"} {
+		if err := f.Set(ctx, newCachedToken(u)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := f.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tk := range tokens {
+		got = append(got, tk.Username)
+	}
+	if len(got) != 2 || got[0] != "alice" || got[1] != "bob" {
+		t.Errorf("got %v, want [alice bob]", got)
+	}
+}
+
+func TestClear(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f := NewFile(dir)
+	if err := f.Set(ctx, newCachedToken("alice")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// cached tokens of the other backend are kept
+	if err := NewEncryptedFile(dir, []byte("passphrase")).Set(ctx, newCachedToken("bob")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := f.Clear(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d, want 2", n)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Ext(files[0]) != ".enc" {
+		t.Errorf("got %v, want only the encrypted cache", files)
+	}
+}
+
+func TestIDDoesNotCollide(t *testing.T) {
+	ctx := context.Background()
+	f := NewFile(t.TempDir())
+	// these keys were mapped to the same ID by replacing ':' and '/' with '_'
+	var keys []userpool.TokenCacheKey
+	for _, username := range []string{"a/b", "a_b", "a:b"} {
+		tk := newCachedToken(username)
+		keys = append(keys, tk.TokenCacheKey)
+		if err := f.Set(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the boundaries of the fields are part of the ID
+	moved := newCachedToken("b")
+	moved.ClientID = "client_a"
+	if ID(moved.TokenCacheKey) == ID(newCachedToken("a_b").TokenCacheKey) {
+		t.Error("IDs of different keys collide")
+	}
+	for _, k := range keys {
+		got, err := f.Get(ctx, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Username != k.Username {
+			t.Errorf("got %s, want %s", got.Username, k.Username)
+		}
+	}
+}
+
+func TestListSkipsOutdatedEntries(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f := NewFile(dir)
+	if err := f.Set(ctx, newCachedToken("alice")); err != nil {
+		t.Fatal(err)
+	}
+	// a cached token written with the ID of an older version
+	b, err := os.ReadFile(filepath.Join(dir, ID(newCachedToken("alice").TokenCacheKey)+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ap-northeast-1_abcdefgh_client_alice_USER_SRP_AUTH.json"), b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := f.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 {
+		t.Errorf("got %d tokens, want 1", len(tokens))
+	}
+}
+
+func TestGetByID(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f := NewFile(dir)
+	tk := newCachedToken("alice")
+	if err := f.Set(ctx, tk); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dir), "outside.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{"ID", ID(tk.TokenCacheKey), false},
+		{"unknown ID", ID(newCachedToken("bob").TokenCacheKey), true},
+		{"not an ID", "../outside", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.GetByID(ctx, tt.id)
+			if tt.wantErr {
+				if !errors.Is(err, userpool.ErrTokenCacheMiss) {
+					t.Errorf("got %v, want ErrTokenCacheMiss", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.TokenCacheKey != tk.TokenCacheKey {
+				t.Errorf("got %+v, want %+v", got.TokenCacheKey, tk.TokenCacheKey)
+			}
+		})
+	}
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	f := NewFile(t.TempDir())
+	alice := newCachedToken("alice").TokenCacheKey
+	unlock, err := f.Lock(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another lock of the same key waits until the lock is released
+	timeout, cancel := context.WithTimeout(ctx, 3*lockRetryDelay)
+	defer cancel()
+	if _, err := f.Lock(timeout, alice); err == nil {
+		t.Error("want error while the key is locked")
+	}
+
+	// other keys are not locked
+	unlockBob, err := f.Lock(ctx, newCachedToken("bob").TokenCacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unlockBob(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err = f.Lock(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,283 @@
+package userpool_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/k1LoW/coglet/userpool"
+)
+
+// memoryTokenCache is a TokenCache in memory.
+type memoryTokenCache struct {
+	mu     sync.Mutex
+	tokens map[userpool.TokenCacheKey]*userpool.CachedToken
+}
+
+func newMemoryTokenCache() *memoryTokenCache {
+	return &memoryTokenCache{tokens: map[userpool.TokenCacheKey]*userpool.CachedToken{}}
+}
+
+func (m *memoryTokenCache) Get(ctx context.Context, key userpool.TokenCacheKey) (*userpool.CachedToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[key]
+	if !ok {
+		return nil, userpool.ErrTokenCacheMiss
+	}
+	return t, nil
+}
+
+func (m *memoryTokenCache) Set(ctx context.Context, token *userpool.CachedToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[token.TokenCacheKey] = token
+	return nil
+}
+
+func (m *memoryTokenCache) Delete(ctx context.Context, key userpool.TokenCacheKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, key)
+	return nil
+}
+
+func (m *memoryTokenCache) List(ctx context.Context) ([]*userpool.CachedToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tokens []*userpool.CachedToken
+	for _, t := range m.tokens {
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// only returns the only cached token.
+func (m *memoryTokenCache) only(t *testing.T) *userpool.CachedToken {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.tokens) != 1 {
+		t.Fatalf("got %d cached tokens, want 1", len(m.tokens))
+	}
+	for _, tk := range m.tokens {
+		return tk
+	}
+	return nil
+}
+
+func TestLoginAsWithTokenCache(t *testing.T) {
+	tests := []struct {
+		name string
+		// modify modifies the cached tokens before the second login
+		modify      func(tk *userpool.CachedToken)
+		password    string
+		wantRefresh bool
+		// keepRefreshToken reports whether the refresh token of the first login is kept
+		keepRefreshToken bool
+		wantErr          bool
+	}{
+		{
+			name:             "cached tokens",
+			modify:           func(tk *userpool.CachedToken) {},
+			password:         "wrong",
+			keepRefreshToken: true,
+		},
+		{
+			name: "renew expired tokens with the refresh token",
+			modify: func(tk *userpool.CachedToken) {
+				tk.ExpiredAt = time.Now().Add(-time.Minute).Unix()
+			},
+			password:    "wrong",
+			wantRefresh: true,
+			// the refresh token is kept because REFRESH_TOKEN_AUTH does not return a new one
+			keepRefreshToken: true,
+		},
+		{
+			name: "log in again when the refresh token is revoked",
+			modify: func(tk *userpool.CachedToken) {
+				tk.ExpiredAt = time.Now().Add(-time.Minute).Unix()
+				tk.Auth.AuthenticationResult.RefreshToken = aws.String("revoked")
+			},
+			password: "Passw0rd!",
+		},
+		{
+			name: "log in again without the refresh token",
+			modify: func(tk *userpool.CachedToken) {
+				tk.ExpiredAt = time.Now().Add(-time.Minute).Unix()
+				tk.Auth.AuthenticationResult.RefreshToken = nil
+			},
+			password: "Passw0rd!",
+		},
+		{
+			name: "expired tokens are not used",
+			modify: func(tk *userpool.CachedToken) {
+				tk.ExpiredAt = time.Now().Add(-time.Minute).Unix()
+				tk.Auth.AuthenticationResult.RefreshToken = nil
+			},
+			password: "wrong",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			clientID, err := api.CreateUserPoolClient(id, "app", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+				t.Fatal(err)
+			}
+			tc := newMemoryTokenCache()
+			opts := []userpool.LoginAsOptionFunc{userpool.WithClientIDOrName(clientID), userpool.WithTokenCache(tc)}
+			first, err := up.LoginAs(ctx, userpool.User{Username: "alice", Password: "Passw0rd!"}, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			firstRefreshToken := aws.ToString(first.AuthenticationResult.RefreshToken)
+			cached := tc.only(t)
+			if cached.Username != "alice" || cached.ClientID != clientID || cached.AuthFlow != "USER_PASSWORD_AUTH" {
+				t.Errorf("got key %+v", cached.TokenCacheKey)
+			}
+			tt.modify(cached)
+			expiredAt := cached.ExpiredAt
+
+			second, err := up.LoginAs(ctx, userpool.User{Username: "alice", Password: tt.password}, opts...)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			cached = tc.only(t)
+			if cached.Expired() {
+				t.Error("cached tokens are expired")
+			}
+			if tt.wantRefresh && cached.ExpiredAt == expiredAt {
+				t.Error("cached tokens are not renewed")
+			}
+			got := aws.ToString(second.AuthenticationResult.RefreshToken)
+			if cached := aws.ToString(cached.Auth.AuthenticationResult.RefreshToken); cached != got {
+				t.Errorf("cached refresh token: got %q, want %q", cached, got)
+			}
+			if tt.keepRefreshToken && got != firstRefreshToken {
+				t.Errorf("refresh token: got %q, want %q", got, firstRefreshToken)
+			}
+			if got == "" || got == "revoked" {
+				t.Errorf("refresh token: got %q, want a valid one", got)
+			}
+			// the refresh token still renews the tokens
+			if _, err := up.RefreshToken(ctx, "alice", got, userpool.WithClientIDOrName(clientID)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// lockingTokenCache is a TokenCache that locks all keys with a single lock.
+type lockingTokenCache struct {
+	*memoryTokenCache
+	lock    sync.Mutex
+	locked  bool
+	locks   int
+	unlocks int
+	sets    int
+	lockErr error
+}
+
+func (l *lockingTokenCache) Lock(ctx context.Context, key userpool.TokenCacheKey) (func() error, error) {
+	if l.lockErr != nil {
+		return nil, l.lockErr
+	}
+	l.lock.Lock()
+	l.mu.Lock()
+	l.locked = true
+	l.locks++
+	l.mu.Unlock()
+	return func() error {
+		l.mu.Lock()
+		l.locked = false
+		l.unlocks++
+		l.mu.Unlock()
+		l.lock.Unlock()
+		return nil
+	}, nil
+}
+
+func (l *lockingTokenCache) Set(ctx context.Context, token *userpool.CachedToken) error {
+	l.mu.Lock()
+	if !l.locked {
+		l.mu.Unlock()
+		return errors.New("token cache is set without the lock")
+	}
+	l.sets++
+	l.mu.Unlock()
+	return l.memoryTokenCache.Set(ctx, token)
+}
+
+func TestLoginAsWithTokenCacheLock(t *testing.T) {
+	ctx := context.Background()
+	api, id, up := newTestClient(t)
+	clientID, err := api.CreateUserPoolClient(id, "app", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("log in only once", func(t *testing.T) {
+		tc := &lockingTokenCache{memoryTokenCache: newMemoryTokenCache()}
+		opts := []userpool.LoginAsOptionFunc{userpool.WithClientIDOrName(clientID), userpool.WithTokenCache(tc)}
+		const n = 5
+		tokens := make([]string, n)
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				out, err := up.LoginAs(ctx, userpool.User{Username: "alice", Password: "Passw0rd!"}, opts...)
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				tokens[i] = aws.ToString(out.AuthenticationResult.IdToken)
+			}()
+		}
+		wg.Wait()
+		if err := errors.Join(errs...); err != nil {
+			t.Fatal(err)
+		}
+		if tc.sets != 1 {
+			t.Errorf("got %d logins, want 1", tc.sets)
+		}
+		if tc.locks != n || tc.unlocks != n {
+			t.Errorf("got %d locks and %d unlocks, want %d", tc.locks, tc.unlocks, n)
+		}
+		for _, tk := range tokens {
+			if tk != tokens[0] {
+				t.Errorf("got different tokens %q and %q", tk, tokens[0])
+			}
+		}
+	})
+
+	t.Run("lock error", func(t *testing.T) {
+		tc := &lockingTokenCache{memoryTokenCache: newMemoryTokenCache(), lockErr: errors.New("lock error")}
+		_, err := up.LoginAs(ctx, userpool.User{Username: "alice", Password: "Passw0rd!"}, userpool.WithClientIDOrName(clientID), userpool.WithTokenCache(tc))
+		if !errors.Is(err, tc.lockErr) {
+			t.Errorf("got %v, want %v", err, tc.lockErr)
+		}
+		if tc.sets != 0 {
+			t.Errorf("got %d logins, want 0", tc.sets)
+		}
+	})
+}
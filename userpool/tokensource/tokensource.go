@@ -0,0 +1,218 @@
+// Package tokensource provides an oauth2.TokenSource and an http.RoundTripper that authenticate as a user of the user pool by userpool.Client.LoginAs.
+package tokensource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/internal/jwt"
+	"github.com/k1LoW/coglet/userpool"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// TokenTypeID uses the ID token as the bearer token.
+	TokenTypeID = "id"
+	// TokenTypeAccess uses the access token as the bearer token.
+	TokenTypeAccess = "access"
+)
+
+// defaultExpiryDelta is how early the tokens are renewed before they expire.
+const defaultExpiryDelta = time.Minute
+
+var (
+	_ oauth2.TokenSource = (*TokenSource)(nil)
+	_ http.RoundTripper  = (*Transport)(nil)
+)
+
+type Option struct {
+	TokenType      string
+	ExpiryDelta    time.Duration
+	LoginAsOptions []userpool.LoginAsOptionFunc
+}
+
+type OptionFunc func(*Option) error
+
+// WithTokenType sets the token used as the bearer token (id|access). The default is access.
+func WithTokenType(tokenType string) OptionFunc {
+	return func(opt *Option) error {
+		switch tokenType {
+		case TokenTypeID, TokenTypeAccess:
+		default:
+			return fmt.Errorf("invalid token type: %s", tokenType)
+		}
+		opt.TokenType = tokenType
+		return nil
+	}
+}
+
+// WithExpiryDelta sets how early the tokens are renewed before they expire. The default is 1 minute.
+func WithExpiryDelta(d time.Duration) OptionFunc {
+	return func(opt *Option) error {
+		if d < 0 {
+			return fmt.Errorf("invalid expiry delta: %s", d)
+		}
+		opt.ExpiryDelta = d
+		return nil
+	}
+}
+
+// WithLoginAsOptions sets the options of LoginAs and RefreshToken.
+func WithLoginAsOptions(opts ...userpool.LoginAsOptionFunc) OptionFunc {
+	return func(opt *Option) error {
+		opt.LoginAsOptions = append(opt.LoginAsOptions, opts...)
+		return nil
+	}
+}
+
+// TokenSource is an oauth2.TokenSource that logs in as the user and renews the tokens with REFRESH_TOKEN_AUTH before they expire.
+// The tokens are held in memory. It is safe for concurrent use, and only one login or refresh runs at a time.
+type TokenSource struct {
+	ctx  context.Context
+	up   *userpool.Client
+	user userpool.User
+	opt  Option
+
+	mu     sync.Mutex
+	auth   *types.AuthenticationResultType
+	expiry time.Time
+}
+
+// New returns a TokenSource of the user. ctx is used by Token to log in and refresh the tokens.
+func New(ctx context.Context, up *userpool.Client, user userpool.User, opts ...OptionFunc) (*TokenSource, error) {
+	opt := Option{
+		TokenType:   TokenTypeAccess,
+		ExpiryDelta: defaultExpiryDelta,
+	}
+	for _, o := range opts {
+		if err := o(&opt); err != nil {
+			return nil, err
+		}
+	}
+	return &TokenSource{
+		ctx:  ctx,
+		up:   up,
+		user: user,
+		opt:  opt,
+	}, nil
+}
+
+// Token returns a valid token. The ID token and the access token are also set as the extra fields "id_token" and "access_token".
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	return s.TokenContext(s.ctx)
+}
+
+// TokenContext is the same as Token but uses ctx to log in and refresh the tokens.
+func (s *TokenSource) TokenContext(ctx context.Context) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.auth == nil || !time.Now().Add(s.opt.ExpiryDelta).Before(s.expiry) {
+		if err := s.renew(ctx); err != nil {
+			return nil, err
+		}
+	}
+	bearer := s.auth.AccessToken
+	if s.opt.TokenType == TokenTypeID {
+		bearer = s.auth.IdToken
+	}
+	t := &oauth2.Token{
+		AccessToken:  aws.ToString(bearer),
+		TokenType:    "Bearer",
+		RefreshToken: aws.ToString(s.auth.RefreshToken),
+		Expiry:       s.expiry,
+	}
+	return t.WithExtra(map[string]any{
+		"id_token":     aws.ToString(s.auth.IdToken),
+		"access_token": aws.ToString(s.auth.AccessToken),
+	}), nil
+}
+
+// Client returns an HTTP client that adds the bearer token to requests.
+func (s *TokenSource) Client() *http.Client {
+	return &http.Client{Transport: &Transport{Source: s}}
+}
+
+// renew refreshes the tokens with the refresh token if any, or logs in as the user.
+func (s *TokenSource) renew(ctx context.Context) error {
+	now := time.Now()
+	if s.auth != nil && s.auth.RefreshToken != nil {
+		out, err := s.up.RefreshToken(ctx, s.user.Username, aws.ToString(s.auth.RefreshToken), s.opt.LoginAsOptions...)
+		if err == nil {
+			// REFRESH_TOKEN_AUTH does not return a new refresh token
+			out.AuthenticationResult.RefreshToken = s.auth.RefreshToken
+			s.set(out.AuthenticationResult, now)
+			return nil
+		}
+		// the refresh token may be expired or revoked
+		slog.Debug("failed to refresh the tokens", slog.String("username", s.user.Username), slog.String("error", err.Error()))
+	}
+	out, err := s.up.LoginAs(ctx, s.user, s.opt.LoginAsOptions...)
+	if err != nil {
+		return err
+	}
+	if out.AuthenticationResult == nil {
+		return fmt.Errorf("%s challenge is required", out.ChallengeName)
+	}
+	s.set(out.AuthenticationResult, now)
+	return nil
+}
+
+// set sets the tokens. The expiry is taken from the exp claim of the bearer token,
+// and falls back to ExpiresIn from issuedAt if the token cannot be decoded.
+func (s *TokenSource) set(auth *types.AuthenticationResultType, issuedAt time.Time) {
+	s.auth = auth
+	bearer := auth.AccessToken
+	if s.opt.TokenType == TokenTypeID {
+		bearer = auth.IdToken
+	}
+	if t, err := jwt.Parse(aws.ToString(bearer)); err == nil {
+		if exp, ok := t.Time("exp"); ok {
+			s.expiry = exp
+			return
+		}
+	}
+	s.expiry = issuedAt.Add(time.Duration(auth.ExpiresIn) * time.Second)
+}
+
+// Transport is an http.RoundTripper that adds the bearer token of the TokenSource to requests.
+type Transport struct {
+	Source *TokenSource
+	// Base is the underlying RoundTripper. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// NewTransport returns a Transport of the TokenSource.
+func NewTransport(s *TokenSource, base http.RoundTripper) *Transport {
+	return &Transport{Source: s, Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	closeBody := func() {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+	}
+	if t.Source == nil {
+		closeBody()
+		return nil, errors.New("no token source")
+	}
+	tok, err := t.Source.TokenContext(req.Context())
+	if err != nil {
+		closeBody()
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	tok.SetAuthHeader(r)
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r)
+}
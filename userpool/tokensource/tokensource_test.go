@@ -0,0 +1,84 @@
+package tokensource_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/internal/jwt"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/coglet/userpool/tokensource"
+	"github.com/k1LoW/coglet/userpool/userpooltest"
+)
+
+func TestTokenSource(t *testing.T) {
+	ctx := context.Background()
+	api := userpooltest.NewAPI()
+	id := api.CreateUserPool("test")
+	cid, err := api.CreateUserPoolClient(id, "app", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.SetExplicitAuthFlows(id, cid, types.ExplicitAuthFlowsTypeAllowUserPasswordAuth); err != nil {
+		t.Fatal(err)
+	}
+	up, err := userpool.New(id, userpool.WithAPI(api))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := userpool.User{Username: "alice", Password: "Passw0rd!"}
+	if err := up.ApplyUser(ctx, userpool.User{Username: user.Username}, userpool.WithPassword(user.Password), userpool.WithPermanentPassword()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tokenType string
+		wantUse   string
+	}{
+		{tokensource.TokenTypeAccess, "access"},
+		{tokensource.TokenTypeID, "id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tokenType, func(t *testing.T) {
+			ts, err := tokensource.New(ctx, up, user, tokensource.WithTokenType(tt.tokenType), tokensource.WithLoginAsOptions(userpool.WithClientIDOrName(cid)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tok, err := ts.Token()
+			if err != nil {
+				t.Fatal(err)
+			}
+			bearer, err := jwt.Parse(tok.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bearer.String("token_use"); got != tt.wantUse {
+				t.Errorf("got token_use %s, want %s", got, tt.wantUse)
+			}
+			// the expiry is the exp claim of the token, not the time of the login plus ExpiresIn
+			exp, ok := bearer.Time("exp")
+			if !ok {
+				t.Fatal("no exp claim")
+			}
+			if !tok.Expiry.Equal(exp) {
+				t.Errorf("got expiry %s, want %s", tok.Expiry, exp)
+			}
+
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Authorization")
+			}))
+			t.Cleanup(srv.Close)
+			res, err := ts.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = res.Body.Close()
+			if want := "Bearer " + tok.AccessToken; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
@@ -16,7 +19,9 @@ import (
 )
 
 type UserPoolOption struct {
-	Endpoint string
+	Endpoint  string
+	API       API
+	RateLimit *float64
 }
 
 type UserPoolOptionFunc func(*UserPoolOption) error
@@ -28,15 +33,41 @@ func WithEndpoint(endpoint string) UserPoolOptionFunc {
 	}
 }
 
+// WithAPI sets the Cognito API implementation used by the client instead of the AWS SDK client.
+func WithAPI(api API) UserPoolOptionFunc {
+	return func(opt *UserPoolOption) error {
+		opt.API = api
+		return nil
+	}
+}
+
+// WithRateLimit limits the request rate of each quota category of Amazon Cognito to rps requests per second.
+// If rps is 0, the default quotas are used. Throttled requests are retried with adaptive back-off instead of the SDK retries.
+func WithRateLimit(rps float64) UserPoolOptionFunc {
+	return func(opt *UserPoolOption) error {
+		if rps < 0 {
+			return fmt.Errorf("invalid rate limit: %v", rps)
+		}
+		opt.RateLimit = &rps
+		return nil
+	}
+}
+
 type Client struct {
 	userPoolID string
-	client     *cognito.Client
+	client     API
+
+	schemaMu  sync.Mutex
+	immutable map[string]bool
 }
 
 type User struct {
 	Username       string            `json:"username"`
 	Password       string            `json:"password,omitempty"`
 	Attributes     map[string]any    `json:"attributes,omitempty"`
+	Groups         []string          `json:"groups,omitempty"`
+	Enabled        *bool             `json:"enabled,omitempty"`
+	MFA            *MFA              `json:"mfa,omitempty"`
 	ClientMetadata map[string]string `json:"clientMetadata,omitempty"`
 }
 
@@ -45,12 +76,36 @@ type ApplyUserOption struct {
 	RandomPassword        bool
 	PermanentPassword     bool
 	SendPasswordResetCode bool
+	ReconcileGroups       bool
 }
 
 type ApplyUserOptionFunc func(*ApplyUserOption) error
 
+// ApplyUserResult is the result of ApplyUserWithResult.
+// Action is update if the password is set, even if there are no other changes.
+type ApplyUserResult struct {
+	UserPlan
+	// Password is the password set to the user, including the generated random password.
+	Password string
+	// Status is the resulting user status.
+	Status types.UserStatusType
+}
+
+type ListUsersOption struct {
+	Filter          string
+	AttributesToGet []string
+}
+
+type ListUsersOptionFunc func(*ListUsersOption) error
+
 type LoginAsOption struct {
 	ClientIDOrName string
+	ClientSecret   string
+	AuthFlow       types.AuthFlowType
+	NewPassword    string
+	TOTPSecret     string
+	SetupTOTP      func(secret string) error
+	TokenCache     TokenCache
 }
 
 type LoginAsOptionFunc func(*LoginAsOption) error
@@ -89,6 +144,30 @@ func WithSendPasswordResetCode() ApplyUserOptionFunc {
 	}
 }
 
+// WithReconcileGroups removes the user from groups that are not in User.Groups.
+// Without it, the user is only added to the groups.
+func WithReconcileGroups() ApplyUserOptionFunc {
+	return func(opt *ApplyUserOption) error {
+		opt.ReconcileGroups = true
+		return nil
+	}
+}
+
+// WithFilter sets the filter expression of ListUsers (e.g. `email ^= "user"`).
+func WithFilter(filter string) ListUsersOptionFunc {
+	return func(opt *ListUsersOption) error {
+		opt.Filter = filter
+		return nil
+	}
+}
+
+func WithAttributesToGet(attrs ...string) ListUsersOptionFunc {
+	return func(opt *ListUsersOption) error {
+		opt.AttributesToGet = attrs
+		return nil
+	}
+}
+
 func WithClientIDOrName(clientIDOrName string) LoginAsOptionFunc {
 	return func(opt *LoginAsOption) error {
 		opt.ClientIDOrName = clientIDOrName
@@ -96,6 +175,64 @@ func WithClientIDOrName(clientIDOrName string) LoginAsOptionFunc {
 	}
 }
 
+// WithClientSecret sets the client secret of the user pool client.
+// When it is set, the client secret is not retrieved by DescribeUserPoolClient.
+func WithClientSecret(clientSecret string) LoginAsOptionFunc {
+	return func(opt *LoginAsOption) error {
+		opt.ClientSecret = clientSecret
+		return nil
+	}
+}
+
+// WithAuthFlow sets the auth flow of LoginAs (USER_PASSWORD_AUTH or USER_SRP_AUTH).
+// When it is not set, the auth flow is selected from the explicit auth flows of the user pool client.
+func WithAuthFlow(flow types.AuthFlowType) LoginAsOptionFunc {
+	return func(opt *LoginAsOption) error {
+		switch flow {
+		case types.AuthFlowTypeUserPasswordAuth, types.AuthFlowTypeUserSrpAuth:
+		default:
+			return fmt.Errorf("unsupported auth flow: %s", flow)
+		}
+		opt.AuthFlow = flow
+		return nil
+	}
+}
+
+// WithNewPassword sets the new password to respond to the NEW_PASSWORD_REQUIRED challenge.
+// Required attributes of the challenge are taken from User.Attributes.
+func WithNewPassword(newPassword string) LoginAsOptionFunc {
+	return func(opt *LoginAsOption) error {
+		opt.NewPassword = newPassword
+		return nil
+	}
+}
+
+// WithTOTPSecret sets the base32 encoded TOTP secret to respond to the SOFTWARE_TOKEN_MFA and SELECT_MFA_TYPE challenges.
+func WithTOTPSecret(secret string) LoginAsOptionFunc {
+	return func(opt *LoginAsOption) error {
+		opt.TOTPSecret = secret
+		return nil
+	}
+}
+
+// WithSetupTOTP enables to respond to the MFA_SETUP challenge by associating a software token.
+// The secret of the associated software token is passed to fn to be stored.
+func WithSetupTOTP(fn func(secret string) error) LoginAsOptionFunc {
+	return func(opt *LoginAsOption) error {
+		opt.SetupTOTP = fn
+		return nil
+	}
+}
+
+// WithTokenCache enables LoginAs to reuse the tokens cached in tc.
+// Expired tokens are renewed with the cached refresh token.
+func WithTokenCache(tc TokenCache) LoginAsOptionFunc {
+	return func(opt *LoginAsOption) error {
+		opt.TokenCache = tc
+		return nil
+	}
+}
+
 func New(userPoolIDOrName string, opts ...UserPoolOptionFunc) (*Client, error) {
 	opt := UserPoolOption{}
 	for _, o := range opts {
@@ -103,18 +240,32 @@ func New(userPoolIDOrName string, opts ...UserPoolOptionFunc) (*Client, error) {
 			return nil, err
 		}
 	}
-	copts := []func(*config.LoadOptions) error{
-		config.WithRetryMaxAttempts(10),
-	}
-	if opt.Endpoint != "" {
-		copts = append(copts, config.WithBaseEndpoint(opt.Endpoint))
-	}
 	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx, copts...)
-	if err != nil {
-		return nil, err
+	client := opt.API
+	if client == nil {
+		copts := []func(*config.LoadOptions) error{
+			config.WithRetryMaxAttempts(10),
+		}
+		if opt.RateLimit != nil {
+			copts = append(copts, config.WithRetryer(func() aws.Retryer {
+				return retry.NewStandard(func(o *retry.StandardOptions) {
+					o.MaxAttempts = 10
+					o.Retryables = append([]retry.IsErrorRetryable{noThrottleRetry}, o.Retryables...)
+				})
+			}))
+		}
+		if opt.Endpoint != "" {
+			copts = append(copts, config.WithBaseEndpoint(opt.Endpoint))
+		}
+		cfg, err := config.LoadDefaultConfig(ctx, copts...)
+		if err != nil {
+			return nil, err
+		}
+		client = cognito.NewFromConfig(cfg)
+	}
+	if opt.RateLimit != nil {
+		client = newRateLimitedAPI(client, *opt.RateLimit)
 	}
-	client := cognito.NewFromConfig(cfg)
 	c := &Client{
 		client: client,
 	}
@@ -131,51 +282,69 @@ func (c *Client) ID() string {
 }
 
 func (c *Client) ApplyUser(ctx context.Context, user User, opts ...ApplyUserOptionFunc) error {
+	_, err := c.ApplyUserWithResult(ctx, user, opts...)
+	return err
+}
+
+// ApplyUserWithResult is the same as ApplyUser but returns what has been applied.
+func (c *Client) ApplyUserWithResult(ctx context.Context, user User, opts ...ApplyUserOptionFunc) (*ApplyUserResult, error) {
 	if user.Username == "" {
-		return errors.New("username is required")
+		return nil, errors.New("username is required")
 	}
 	var opt ApplyUserOption
 	for _, o := range opts {
 		if err := o(&opt); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	ok, err := c.userExists(ctx, user.Username)
+	current, err := c.getUser(ctx, user.Username)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var currentGroups []string
+	if current != nil && user.Groups != nil {
+		currentGroups, err = c.userGroups(ctx, user.Username)
+		if err != nil {
+			return nil, err
+		}
 	}
-	if !ok {
+	plan, status := diffUser(user, opt, current, currentGroups)
+	if current == nil {
 		// create user
 		if err := c.createUser(ctx, user); err != nil {
-			return err
+			return nil, err
 		}
 	}
 	// update user attributes
 	if err := c.updateUserAttributes(ctx, user); err != nil {
-		return err
+		return nil, err
+	}
+
+	// update user groups
+	if err := c.updateUserGroups(ctx, user, currentGroups, opt.ReconcileGroups); err != nil {
+		return nil, err
+	}
+
+	// update user enabled state
+	if err := c.updateUserEnabled(ctx, user, current); err != nil {
+		return nil, err
 	}
 
 	switch {
 	case opt.Password != "":
 		user.Password = opt.Password
 	case opt.RandomPassword:
-		p, err := c.client.DescribeUserPool(ctx, &cognito.DescribeUserPoolInput{
-			UserPoolId: aws.String(c.userPoolID),
-		})
+		password, err := c.GeneratePassword(ctx)
 		if err != nil {
-			return err
-		}
-		password, err := generatePassword(*p.UserPool.Policies.PasswordPolicy)
-		if err != nil {
-			return err
+			return nil, err
 		}
 		user.Password = password
 	}
 
 	// update user password
 	if err := c.updateUserPassword(ctx, user, opt.PermanentPassword); err != nil {
-		return err
+		return nil, err
 	}
 
 	if opt.SendPasswordResetCode {
@@ -184,11 +353,80 @@ func (c *Client) ApplyUser(ctx context.Context, user User, opts ...ApplyUserOpti
 			UserPoolId: aws.String(c.userPoolID),
 			Username:   aws.String(user.Username),
 		}); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	result := &ApplyUserResult{
+		UserPlan: *plan,
+		Password: user.Password,
+		Status:   status,
+	}
+	if result.Action == PlanActionNoop && user.Password != "" {
+		// the password is set even if the status does not change
+		result.Action = PlanActionUpdate
+	}
+	return result, nil
+}
+
+// ListUsers returns all users in the user pool.
+func (c *Client) ListUsers(ctx context.Context, opts ...ListUsersOptionFunc) ([]types.UserType, error) {
+	var opt ListUsersOption
+	for _, o := range opts {
+		if err := o(&opt); err != nil {
+			return nil, err
+		}
+	}
+	var users []types.UserType
+	var paginationToken *string
+	for {
+		resp, err := c.client.ListUsers(ctx, &cognito.ListUsersInput{
+			UserPoolId:      aws.String(c.userPoolID),
+			Filter:          optionalString(opt.Filter),
+			AttributesToGet: opt.AttributesToGet,
+			Limit:           aws.Int32(60),
+			PaginationToken: paginationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, resp.Users...)
+		if resp.PaginationToken == nil {
+			break
+		}
+		paginationToken = resp.PaginationToken
+	}
+	return users, nil
+}
+
+// ResolveUsername returns the username of the user that username refers to.
+// In a user pool with username attributes or aliases, username can be an email address, a phone number or a sub.
+// It returns an empty string if the user does not exist.
+func (c *Client) ResolveUsername(ctx context.Context, username string) (string, error) {
+	out, err := c.getUser(ctx, username)
+	if err != nil {
+		return "", err
+	}
+	if out == nil {
+		return "", nil
+	}
+	return aws.ToString(out.Username), nil
+}
+
+func (c *Client) DeleteUser(ctx context.Context, username string) error {
+	_, err := c.client.AdminDeleteUser(ctx, &cognito.AdminDeleteUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	})
+	return err
+}
+
+func (c *Client) DisableUser(ctx context.Context, username string) error {
+	_, err := c.client.AdminDisableUser(ctx, &cognito.AdminDisableUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	})
+	return err
 }
 
 func (c *Client) LoginAs(ctx context.Context, user User, opts ...LoginAsOptionFunc) (*cognito.InitiateAuthOutput, error) {
@@ -199,69 +437,202 @@ func (c *Client) LoginAs(ctx context.Context, user User, opts ...LoginAsOptionFu
 		}
 	}
 
+	ac, err := c.appClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	flow := opt.AuthFlow
+	if flow == "" {
+		flow = ac.authFlow()
+	}
+	if opt.TokenCache != nil {
+		key := TokenCacheKey{
+			UserPoolID: c.userPoolID,
+			ClientID:   ac.id,
+			Username:   user.Username,
+			AuthFlow:   string(flow),
+		}
+		return c.loginAsWithCache(ctx, ac, flow, user, opt, key)
+	}
+	return c.loginAs(ctx, ac, flow, user, opt)
+}
+
+func (c *Client) loginAs(ctx context.Context, ac *appClient, flow types.AuthFlowType, user User, opt LoginAsOption) (*cognito.InitiateAuthOutput, error) {
+	var (
+		out *cognito.InitiateAuthOutput
+		err error
+	)
+	if flow == types.AuthFlowTypeUserSrpAuth {
+		out, err = c.loginWithSRP(ctx, ac, user)
+	} else {
+		params := map[string]string{
+			"USERNAME": user.Username,
+			"PASSWORD": user.Password,
+		}
+		if ac.secret != "" {
+			params["SECRET_HASH"] = secretHash(ac.id, ac.secret, user.Username)
+		}
+		input := &cognito.InitiateAuthInput{
+			ClientId:       aws.String(ac.id),
+			AuthFlow:       types.AuthFlowTypeUserPasswordAuth,
+			AuthParameters: params,
+			ClientMetadata: user.ClientMetadata,
+		}
+
+		// use initiated-login
+		out, err = c.client.InitiateAuth(ctx, input)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.respondToChallenges(ctx, ac, user, opt, out)
+}
+
+// RefreshToken renews the tokens of the user with the refresh token by REFRESH_TOKEN_AUTH.
+// The output does not contain a new refresh token.
+func (c *Client) RefreshToken(ctx context.Context, username, refreshToken string, opts ...LoginAsOptionFunc) (*cognito.InitiateAuthOutput, error) {
+	opt := LoginAsOption{}
+	for _, o := range opts {
+		if err := o(&opt); err != nil {
+			return nil, err
+		}
+	}
+
+	ac, err := c.appClient(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return c.refreshToken(ctx, ac, username, refreshToken)
+}
+
+func (c *Client) refreshToken(ctx context.Context, ac *appClient, username, refreshToken string) (*cognito.InitiateAuthOutput, error) {
+	params := map[string]string{
+		"REFRESH_TOKEN": refreshToken,
+	}
+	if ac.secret != "" {
+		params["SECRET_HASH"] = secretHash(ac.id, ac.secret, username)
+	}
+	out, err := c.client.InitiateAuth(ctx, &cognito.InitiateAuthInput{
+		ClientId:       aws.String(ac.id),
+		AuthFlow:       types.AuthFlowTypeRefreshTokenAuth,
+		AuthParameters: params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.AuthenticationResult == nil {
+		return nil, fmt.Errorf("%s challenge is required", out.ChallengeName)
+	}
+	return out, nil
+}
+
+// GeneratePassword generates a random password that complies with the password policy of the user pool.
+func (c *Client) GeneratePassword(ctx context.Context) (string, error) {
+	p, err := c.client.DescribeUserPool(ctx, &cognito.DescribeUserPoolInput{
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	if err != nil {
+		return "", err
+	}
+	return generatePassword(*p.UserPool.Policies.PasswordPolicy)
+}
+
+// appClient is the user pool client used for authentication.
+type appClient struct {
+	id        string
+	secret    string
+	authFlows []types.ExplicitAuthFlowsType
+}
+
+// authFlow returns the auth flow allowed for the client.
+// USER_PASSWORD_AUTH is preferred, and USER_SRP_AUTH is used when only it is allowed.
+func (ac *appClient) authFlow() types.AuthFlowType {
+	if ac.authFlows == nil {
+		// unknown
+		return types.AuthFlowTypeUserPasswordAuth
+	}
+	if slices.Contains(ac.authFlows, types.ExplicitAuthFlowsTypeAllowUserPasswordAuth) ||
+		slices.Contains(ac.authFlows, types.ExplicitAuthFlowsTypeUserPasswordAuth) {
+		return types.AuthFlowTypeUserPasswordAuth
+	}
+	if len(ac.authFlows) == 0 || slices.Contains(ac.authFlows, types.ExplicitAuthFlowsTypeAllowUserSrpAuth) {
+		// USER_SRP_AUTH is allowed by default
+		return types.AuthFlowTypeUserSrpAuth
+	}
+	return types.AuthFlowTypeUserPasswordAuth
+}
+
+func (c *Client) appClient(ctx context.Context, opt LoginAsOption) (*appClient, error) {
+	clientID, err := c.detectClientID(ctx, opt.ClientIDOrName)
+	if err != nil {
+		return nil, err
+	}
+	if opt.ClientSecret != "" {
+		// the client secret is given, so DescribeUserPoolClient is not required
+		return &appClient{
+			id:     clientID,
+			secret: opt.ClientSecret,
+		}, nil
+	}
+
+	uc, err := c.client.DescribeUserPoolClient(ctx, &cognito.DescribeUserPoolClientInput{
+		UserPoolId: aws.String(c.userPoolID),
+		ClientId:   aws.String(clientID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &appClient{
+		id:        clientID,
+		secret:    aws.ToString(uc.UserPoolClient.ClientSecret), // public clients have no client secret
+		authFlows: append([]types.ExplicitAuthFlowsType{}, uc.UserPoolClient.ExplicitAuthFlows...),
+	}, nil
+}
+
+func (c *Client) detectClientID(ctx context.Context, clientIDOrName string) (string, error) {
 	// list user pool clients
 	out, err := c.client.ListUserPoolClients(ctx, &cognito.ListUserPoolClientsInput{
 		UserPoolId: aws.String(c.userPoolID),
 	})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	if len(out.UserPoolClients) == 0 {
-		return nil, errors.New("no user pool clients found")
+		return "", errors.New("no user pool clients found")
 	}
 	var (
 		clientID *string
 	)
 
 	if len(out.UserPoolClients) == 1 {
-		if opt.ClientIDOrName != "" {
-			if *out.UserPoolClients[0].ClientId != opt.ClientIDOrName && *out.UserPoolClients[0].ClientName != opt.ClientIDOrName {
-				return nil, fmt.Errorf("client not found: %s", opt.ClientIDOrName)
+		if clientIDOrName != "" {
+			if *out.UserPoolClients[0].ClientId != clientIDOrName && *out.UserPoolClients[0].ClientName != clientIDOrName {
+				return "", fmt.Errorf("client not found: %s", clientIDOrName)
 			}
 		}
 		clientID = out.UserPoolClients[0].ClientId
 	} else {
-		if opt.ClientIDOrName == "" {
-			return nil, errors.New("client ID or name is required")
+		if clientIDOrName == "" {
+			return "", errors.New("client ID or name is required")
 		}
 		for _, c := range out.UserPoolClients {
-			if *c.ClientId == opt.ClientIDOrName {
+			if *c.ClientId == clientIDOrName {
 				clientID = c.ClientId
 				break
 			}
-			if *c.ClientName == opt.ClientIDOrName {
+			if *c.ClientName == clientIDOrName {
 				if clientID != nil {
-					return nil, fmt.Errorf("client name is ambiguous: %s", opt.ClientIDOrName)
+					return "", fmt.Errorf("client name is ambiguous: %s", clientIDOrName)
 				}
 				clientID = c.ClientId
 			}
 		}
 		if clientID == nil {
-			return nil, fmt.Errorf("client not found: %s", opt.ClientIDOrName)
+			return "", fmt.Errorf("client not found: %s", clientIDOrName)
 		}
 	}
-
-	uc, err := c.client.DescribeUserPoolClient(ctx, &cognito.DescribeUserPoolClientInput{
-		UserPoolId: aws.String(c.userPoolID),
-		ClientId:   clientID,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	input := &cognito.InitiateAuthInput{
-		ClientId: clientID,
-		AuthFlow: types.AuthFlowTypeUserPasswordAuth,
-		AuthParameters: map[string]string{
-			"USERNAME":    user.Username,
-			"PASSWORD":    user.Password,
-			"SECRET_HASH": secretHash(*clientID, *uc.UserPoolClient.ClientSecret, user.Username),
-		},
-		ClientMetadata: user.ClientMetadata,
-	}
-
-	// use initiated-login
-	return c.client.InitiateAuth(ctx, input)
+	return *clientID, nil
 }
 
 func (c *Client) createUser(ctx context.Context, user User) error {
@@ -281,7 +652,7 @@ func (c *Client) updateUserAttributes(ctx context.Context, user User) error {
 	for key, value := range user.Attributes {
 		userAttrs = append(userAttrs, types.AttributeType{
 			Name:  aws.String(key),
-			Value: aws.String(fmt.Sprintf("%v", value)),
+			Value: aws.String(attributeValue(value)),
 		})
 	}
 
@@ -297,19 +668,102 @@ func (c *Client) updateUserAttributes(ctx context.Context, user User) error {
 	return nil
 }
 
-func (c *Client) userExists(ctx context.Context, username string) (bool, error) {
-	if _, err := c.client.AdminGetUser(ctx, &cognito.AdminGetUserInput{
+func (c *Client) updateUserGroups(ctx context.Context, user User, current []string, reconcile bool) error {
+	if user.Groups == nil {
+		return nil
+	}
+	for _, g := range user.Groups {
+		if slices.Contains(current, g) {
+			continue
+		}
+		if _, err := c.client.AdminAddUserToGroup(ctx, &cognito.AdminAddUserToGroupInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(user.Username),
+			GroupName:  aws.String(g),
+		}); err != nil {
+			var notFound *types.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				return fmt.Errorf("group not found: %s: %w", g, err)
+			}
+			return err
+		}
+	}
+	if !reconcile {
+		return nil
+	}
+	for _, g := range current {
+		if slices.Contains(user.Groups, g) {
+			continue
+		}
+		if _, err := c.client.AdminRemoveUserFromGroup(ctx, &cognito.AdminRemoveUserFromGroupInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(user.Username),
+			GroupName:  aws.String(g),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) userGroups(ctx context.Context, username string) ([]string, error) {
+	var groups []string
+	var nextToken *string
+	for {
+		resp, err := c.client.AdminListGroupsForUser(ctx, &cognito.AdminListGroupsForUserInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(username),
+			Limit:      aws.Int32(60),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range resp.Groups {
+			groups = append(groups, aws.ToString(g.GroupName))
+		}
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+	return groups, nil
+}
+
+// getUser returns the user in the user pool, or nil if the user does not exist.
+func (c *Client) getUser(ctx context.Context, username string) (*cognito.AdminGetUserOutput, error) {
+	out, err := c.client.AdminGetUser(ctx, &cognito.AdminGetUserInput{
 		UserPoolId: aws.String(c.userPoolID),
 		Username:   aws.String(username),
-	}); err != nil {
+	})
+	if err != nil {
 		var userNotFound *types.UserNotFoundException
 		if errors.As(err, &userNotFound) {
-			return false, nil
+			return nil, nil
 		}
-		return false, err
+		return nil, err
 	}
 
-	return true, nil
+	return out, nil
+}
+
+func (c *Client) updateUserEnabled(ctx context.Context, user User, current *cognito.AdminGetUserOutput) error {
+	if user.Enabled == nil {
+		return nil
+	}
+	// created users are enabled
+	enabled := current == nil || current.Enabled
+	if *user.Enabled == enabled {
+		return nil
+	}
+	if *user.Enabled {
+		_, err := c.client.AdminEnableUser(ctx, &cognito.AdminEnableUserInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(user.Username),
+		})
+		return err
+	}
+	return c.DisableUser(ctx, user.Username)
 }
 
 func (c *Client) updateUserPassword(ctx context.Context, user User, permanent bool) error {
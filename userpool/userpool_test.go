@@ -0,0 +1,659 @@
+package userpool_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/userpool"
+	"github.com/k1LoW/coglet/userpool/userpooltest"
+)
+
+func newTestClient(t *testing.T) (*userpooltest.API, string, *userpool.Client) {
+	t.Helper()
+	api := userpooltest.NewAPI()
+	id := api.CreateUserPool("test")
+	up, err := userpool.New(id, userpool.WithAPI(api))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range []string{"admin", "staff", "guest"} {
+		if err := up.ApplyGroup(context.Background(), userpool.Group{Name: g}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return api, id, up
+}
+
+func TestApplyUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     *userpool.User
+		user        userpool.User
+		opts        []userpool.ApplyUserOptionFunc
+		wantAttrs   map[string]string
+		wantGroups  []string
+		wantEnabled bool
+		wantStatus  types.UserStatusType
+	}{
+		{
+			name:        "create",
+			user:        userpool.User{Username: "alice", Attributes: map[string]any{"email": "alice@example.com"}},
+			wantAttrs:   map[string]string{"email": "alice@example.com"},
+			wantEnabled: true,
+			wantStatus:  types.UserStatusTypeForceChangePassword,
+		},
+		{
+			name:        "update attributes",
+			current:     &userpool.User{Username: "alice", Attributes: map[string]any{"email": "old@example.com", "name": "Alice"}},
+			user:        userpool.User{Username: "alice", Attributes: map[string]any{"email": "alice@example.com"}},
+			wantAttrs:   map[string]string{"email": "alice@example.com", "name": "Alice"},
+			wantEnabled: true,
+			wantStatus:  types.UserStatusTypeForceChangePassword,
+		},
+		{
+			name:        "permanent password",
+			user:        userpool.User{Username: "alice"},
+			opts:        []userpool.ApplyUserOptionFunc{userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()},
+			wantEnabled: true,
+			wantStatus:  types.UserStatusTypeConfirmed,
+		},
+		{
+			name:        "disable",
+			current:     &userpool.User{Username: "alice"},
+			user:        userpool.User{Username: "alice", Enabled: aws.Bool(false)},
+			wantEnabled: false,
+			wantStatus:  types.UserStatusTypeForceChangePassword,
+		},
+		{
+			name:        "add groups",
+			current:     &userpool.User{Username: "alice", Groups: []string{"guest"}},
+			user:        userpool.User{Username: "alice", Groups: []string{"admin", "staff"}},
+			wantGroups:  []string{"admin", "guest", "staff"},
+			wantEnabled: true,
+			wantStatus:  types.UserStatusTypeForceChangePassword,
+		},
+		{
+			name:        "reconcile groups",
+			current:     &userpool.User{Username: "alice", Groups: []string{"guest", "staff"}},
+			user:        userpool.User{Username: "alice", Groups: []string{"admin", "staff"}},
+			opts:        []userpool.ApplyUserOptionFunc{userpool.WithReconcileGroups()},
+			wantGroups:  []string{"admin", "staff"},
+			wantEnabled: true,
+			wantStatus:  types.UserStatusTypeForceChangePassword,
+		},
+		{
+			name:        "reconcile without groups does not touch groups",
+			current:     &userpool.User{Username: "alice", Groups: []string{"guest"}},
+			user:        userpool.User{Username: "alice"},
+			opts:        []userpool.ApplyUserOptionFunc{userpool.WithReconcileGroups()},
+			wantGroups:  []string{"guest"},
+			wantEnabled: true,
+			wantStatus:  types.UserStatusTypeForceChangePassword,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			if tt.current != nil {
+				if err := up.ApplyUser(ctx, *tt.current); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := up.ApplyUser(ctx, tt.user, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			got, ok := api.GetUser(id, tt.user.Username)
+			if !ok {
+				t.Fatalf("user %s not found", tt.user.Username)
+			}
+			for k, v := range tt.wantAttrs {
+				if got.Attributes[k] != v {
+					t.Errorf("attribute %s: got %q, want %q", k, got.Attributes[k], v)
+				}
+			}
+			groups := slices.Sorted(slices.Values(got.Groups))
+			if !slices.Equal(groups, tt.wantGroups) {
+				t.Errorf("groups: got %v, want %v", groups, tt.wantGroups)
+			}
+			if got.Enabled != tt.wantEnabled {
+				t.Errorf("enabled: got %v, want %v", got.Enabled, tt.wantEnabled)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status: got %s, want %s", got.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestPlanUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     *userpool.User
+		user        userpool.User
+		opts        []userpool.ApplyUserOptionFunc
+		wantAction  userpool.PlanAction
+		wantChanges []string
+	}{
+		{
+			name:        "create",
+			user:        userpool.User{Username: "alice", Attributes: map[string]any{"email": "alice@example.com"}, Groups: []string{"admin"}},
+			wantAction:  userpool.PlanActionCreate,
+			wantChanges: []string{"email", "groups", "status"},
+		},
+		{
+			name:       "no-op",
+			current:    &userpool.User{Username: "alice", Attributes: map[string]any{"email": "alice@example.com"}, Groups: []string{"admin"}},
+			user:       userpool.User{Username: "alice", Attributes: map[string]any{"email": "alice@example.com"}, Groups: []string{"admin"}},
+			wantAction: userpool.PlanActionNoop,
+		},
+		{
+			name:        "update",
+			current:     &userpool.User{Username: "alice", Attributes: map[string]any{"email": "old@example.com"}},
+			user:        userpool.User{Username: "alice", Attributes: map[string]any{"email": "alice@example.com"}, Enabled: aws.Bool(false)},
+			wantAction:  userpool.PlanActionUpdate,
+			wantChanges: []string{"email", "enabled"},
+		},
+		{
+			name:       "groups without reconcile are kept",
+			current:    &userpool.User{Username: "alice", Groups: []string{"admin", "guest"}},
+			user:       userpool.User{Username: "alice", Groups: []string{"admin"}},
+			wantAction: userpool.PlanActionNoop,
+		},
+		{
+			name:        "reconcile groups",
+			current:     &userpool.User{Username: "alice", Groups: []string{"admin", "guest"}},
+			user:        userpool.User{Username: "alice", Groups: []string{"admin"}},
+			opts:        []userpool.ApplyUserOptionFunc{userpool.WithReconcileGroups()},
+			wantAction:  userpool.PlanActionUpdate,
+			wantChanges: []string{"groups"},
+		},
+		{
+			name:        "permanent password",
+			current:     &userpool.User{Username: "alice"},
+			user:        userpool.User{Username: "alice"},
+			opts:        []userpool.ApplyUserOptionFunc{userpool.WithRandomPassword(), userpool.WithPermanentPassword()},
+			wantAction:  userpool.PlanActionUpdate,
+			wantChanges: []string{"status"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			if tt.current != nil {
+				if err := up.ApplyUser(ctx, *tt.current); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before, _ := api.GetUser(id, tt.user.Username)
+			p, err := up.PlanUser(ctx, tt.user, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Action != tt.wantAction {
+				t.Errorf("action: got %s, want %s", p.Action, tt.wantAction)
+			}
+			var names []string
+			for _, c := range p.Changes {
+				names = append(names, c.Name)
+			}
+			if !slices.Equal(names, tt.wantChanges) {
+				t.Errorf("changes: got %v, want %v", names, tt.wantChanges)
+			}
+			// plan does not change the user pool
+			after, _ := api.GetUser(id, tt.user.Username)
+			if before.Status != after.Status || before.Enabled != after.Enabled || len(before.Groups) != len(after.Groups) {
+				t.Errorf("user changed by plan: before %+v, after %+v", before, after)
+			}
+		})
+	}
+}
+
+func TestPruneUser(t *testing.T) {
+	tests := []struct {
+		name        string
+		prune       func(up *userpool.Client, ctx context.Context, username string) error
+		wantExists  bool
+		wantEnabled bool
+		wantUsers   int
+	}{
+		{
+			name:       "delete",
+			prune:      (*userpool.Client).DeleteUser,
+			wantExists: false,
+			wantUsers:  1,
+		},
+		{
+			name:        "disable",
+			prune:       (*userpool.Client).DisableUser,
+			wantExists:  true,
+			wantEnabled: false,
+			wantUsers:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			for _, u := range []string{"alice", "bob"} {
+				if err := up.ApplyUser(ctx, userpool.User{Username: u}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.prune(up, ctx, "bob"); err != nil {
+				t.Fatal(err)
+			}
+			got, ok := api.GetUser(id, "bob")
+			if ok != tt.wantExists {
+				t.Fatalf("exists: got %v, want %v", ok, tt.wantExists)
+			}
+			if ok && got.Enabled != tt.wantEnabled {
+				t.Errorf("enabled: got %v, want %v", got.Enabled, tt.wantEnabled)
+			}
+			if _, ok := api.GetUser(id, "alice"); !ok {
+				t.Error("alice should not be pruned")
+			}
+			users, err := up.ListUsers(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != tt.wantUsers {
+				t.Errorf("users: got %d, want %d", len(users), tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestLoginAsWithSRP(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{"correct password", "Passw0rd!", false},
+		{"wrong password", "wrong", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			clientID, err := api.CreateUserPoolClient(id, "app", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := api.SetExplicitAuthFlows(id, clientID, types.ExplicitAuthFlowsTypeAllowUserSrpAuth); err != nil {
+				t.Fatal(err)
+			}
+			if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+				t.Fatal(err)
+			}
+			out, err := up.LoginAs(ctx, userpool.User{Username: "alice", Password: tt.password}, userpool.WithClientIDOrName(clientID), userpool.WithAuthFlow(types.AuthFlowTypeUserSrpAuth))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.AuthenticationResult == nil || aws.ToString(out.AuthenticationResult.IdToken) == "" {
+				t.Errorf("got %+v, want tokens", out)
+			}
+		})
+	}
+}
+
+func TestLoginAsNewPasswordRequired(t *testing.T) {
+	tests := []struct {
+		name          string
+		required      []string
+		user          userpool.User
+		opts          []userpool.LoginAsOptionFunc
+		wantChallenge types.ChallengeNameType
+		wantErr       bool
+	}{
+		{
+			name:          "challenge without new password",
+			user:          userpool.User{Username: "alice", Password: "TempPassw0rd!"},
+			wantChallenge: types.ChallengeNameTypeNewPasswordRequired,
+		},
+		{
+			name: "new password",
+			user: userpool.User{Username: "alice", Password: "TempPassw0rd!"},
+			opts: []userpool.LoginAsOptionFunc{userpool.WithNewPassword("NewPassw0rd!")},
+		},
+		{
+			name:     "new password with required attributes",
+			required: []string{"name"},
+			user:     userpool.User{Username: "alice", Password: "TempPassw0rd!", Attributes: map[string]any{"name": "Alice"}},
+			opts:     []userpool.LoginAsOptionFunc{userpool.WithNewPassword("NewPassw0rd!")},
+		},
+		{
+			name:     "missing required attributes",
+			required: []string{"name"},
+			user:     userpool.User{Username: "alice", Password: "TempPassw0rd!"},
+			opts:     []userpool.LoginAsOptionFunc{userpool.WithNewPassword("NewPassw0rd!")},
+			wantErr:  true,
+		},
+		{
+			name:    "new password violating the password policy",
+			user:    userpool.User{Username: "alice", Password: "TempPassw0rd!"},
+			opts:    []userpool.LoginAsOptionFunc{userpool.WithNewPassword("weak")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			clientID, err := api.CreateUserPoolClient(id, "app", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("TempPassw0rd!")); err != nil {
+				t.Fatal(err)
+			}
+			if err := api.SetRequiredAttributes(id, tt.required...); err != nil {
+				t.Fatal(err)
+			}
+			out, err := up.LoginAs(ctx, tt.user, append([]userpool.LoginAsOptionFunc{userpool.WithClientIDOrName(clientID)}, tt.opts...)...)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.ChallengeName != tt.wantChallenge {
+				t.Errorf("challenge: got %s, want %s", out.ChallengeName, tt.wantChallenge)
+			}
+			got, _ := api.GetUser(id, "alice")
+			if tt.wantChallenge != "" {
+				if out.AuthenticationResult != nil {
+					t.Error("want no tokens")
+				}
+				if got.Status != types.UserStatusTypeForceChangePassword {
+					t.Errorf("status: got %s, want %s", got.Status, types.UserStatusTypeForceChangePassword)
+				}
+				return
+			}
+			if out.AuthenticationResult == nil || aws.ToString(out.AuthenticationResult.IdToken) == "" {
+				t.Errorf("got %+v, want tokens", out)
+			}
+			if got.Status != types.UserStatusTypeConfirmed {
+				t.Errorf("status: got %s, want %s", got.Status, types.UserStatusTypeConfirmed)
+			}
+			if got.Password != "NewPassw0rd!" {
+				t.Errorf("password: got %q, want %q", got.Password, "NewPassw0rd!")
+			}
+			for _, name := range tt.required {
+				if got.Attributes[name] != tt.user.Attributes[name] {
+					t.Errorf("attribute %s: got %q, want %q", name, got.Attributes[name], tt.user.Attributes[name])
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy types.PasswordPolicyType
+	}{
+		{"default", types.PasswordPolicyType{MinimumLength: aws.Int32(8), RequireLowercase: true, RequireNumbers: true, RequireSymbols: true, RequireUppercase: true}},
+		{"long", types.PasswordPolicyType{MinimumLength: aws.Int32(32), RequireLowercase: true, RequireNumbers: true, RequireSymbols: true, RequireUppercase: true}},
+		{"lowercase and numbers", types.PasswordPolicyType{MinimumLength: aws.Int32(12), RequireLowercase: true, RequireNumbers: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			if err := api.SetPasswordPolicy(id, tt.policy); err != nil {
+				t.Fatal(err)
+			}
+			clientID, err := api.CreateUserPoolClient(id, "app", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pw, err := up.GeneratePassword(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pw) < int(*tt.policy.MinimumLength) {
+				t.Errorf("got %q, want at least %d characters", pw, *tt.policy.MinimumLength)
+			}
+			// the generated password is accepted as a new password by the user pool
+			np, err := up.GeneratePassword(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword(pw)); err != nil {
+				t.Fatal(err)
+			}
+			out, err := up.LoginAs(ctx, userpool.User{Username: "alice", Password: pw}, userpool.WithClientIDOrName(clientID), userpool.WithNewPassword(np))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.AuthenticationResult == nil {
+				t.Errorf("got %+v, want tokens", out)
+			}
+		})
+	}
+}
+
+func TestLoginAsTOTP(t *testing.T) {
+	tests := []struct {
+		name          string
+		mfa           types.UserPoolMfaType
+		enroll        bool
+		sms           bool
+		secret        string // "enrolled" is replaced with the enrolled secret
+		setup         bool
+		wantChallenge types.ChallengeNameType
+		wantErr       bool
+	}{
+		{
+			name:   "SOFTWARE_TOKEN_MFA",
+			mfa:    types.UserPoolMfaTypeOptional,
+			enroll: true,
+			secret: "enrolled",
+		},
+		{
+			name:          "SOFTWARE_TOKEN_MFA without secret",
+			mfa:           types.UserPoolMfaTypeOptional,
+			enroll:        true,
+			wantChallenge: types.ChallengeNameTypeSoftwareTokenMfa,
+		},
+		{
+			name:    "SOFTWARE_TOKEN_MFA with wrong secret",
+			mfa:     types.UserPoolMfaTypeOptional,
+			enroll:  true,
+			secret:  "JBSWY3DPEHPK3PXP",
+			wantErr: true,
+		},
+		{
+			name:   "SELECT_MFA_TYPE",
+			mfa:    types.UserPoolMfaTypeOptional,
+			enroll: true,
+			sms:    true,
+			secret: "enrolled",
+		},
+		{
+			name:          "SELECT_MFA_TYPE without secret",
+			mfa:           types.UserPoolMfaTypeOptional,
+			enroll:        true,
+			sms:           true,
+			wantChallenge: types.ChallengeNameTypeSelectMfaType,
+		},
+		{
+			name:  "MFA_SETUP",
+			mfa:   types.UserPoolMfaTypeOn,
+			setup: true,
+		},
+		{
+			name:          "MFA_SETUP without setup",
+			mfa:           types.UserPoolMfaTypeOn,
+			wantChallenge: types.ChallengeNameTypeMfaSetup,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			clientID, err := api.CreateUserPoolClient(id, "app", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+				t.Fatal(err)
+			}
+			if err := api.SetMFAConfiguration(id, tt.mfa); err != nil {
+				t.Fatal(err)
+			}
+			secret := tt.secret
+			if tt.enroll {
+				enrolled, err := api.EnableSoftwareTokenMFA(id, "alice")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if secret == "enrolled" {
+					secret = enrolled
+				}
+			}
+			if tt.sms {
+				// both MFA types are enabled and neither is preferred
+				if _, err := api.AdminSetUserMFAPreference(ctx, &cognito.AdminSetUserMFAPreferenceInput{
+					UserPoolId:               aws.String(id),
+					Username:                 aws.String("alice"),
+					SMSMfaSettings:           &types.SMSMfaSettingsType{Enabled: true},
+					SoftwareTokenMfaSettings: &types.SoftwareTokenMfaSettingsType{Enabled: true},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			opts := []userpool.LoginAsOptionFunc{userpool.WithClientIDOrName(clientID), userpool.WithTOTPSecret(secret)}
+			var setupSecret string
+			if tt.setup {
+				opts = append(opts, userpool.WithSetupTOTP(func(secret string) error {
+					setupSecret = secret
+					return nil
+				}))
+			}
+			out, err := up.LoginAs(ctx, userpool.User{Username: "alice", Password: "Passw0rd!"}, opts...)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.ChallengeName != tt.wantChallenge {
+				t.Errorf("challenge: got %s, want %s", out.ChallengeName, tt.wantChallenge)
+			}
+			if tt.wantChallenge != "" {
+				return
+			}
+			if out.AuthenticationResult == nil || aws.ToString(out.AuthenticationResult.IdToken) == "" {
+				t.Errorf("got %+v, want tokens", out)
+			}
+			if tt.setup {
+				if setupSecret == "" {
+					t.Fatal("the set up secret is not reported")
+				}
+				// the set up secret answers the SOFTWARE_TOKEN_MFA challenge from now on
+				if _, err := up.LoginAs(ctx, userpool.User{Username: "alice", Password: "Passw0rd!"}, userpool.WithClientIDOrName(clientID), userpool.WithTOTPSecret(setupSecret)); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestEnrollSoftwareToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		mfa    types.UserPoolMfaType
+		enroll bool
+	}{
+		{"MFA off", types.UserPoolMfaTypeOff, false},
+		{"MFA optional", types.UserPoolMfaTypeOptional, false},
+		{"MFA required (MFA_SETUP)", types.UserPoolMfaTypeOn, false},
+		{"re-enroll", types.UserPoolMfaTypeOptional, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api, id, up := newTestClient(t)
+			clientID, err := api.CreateUserPoolClient(id, "app", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := up.ApplyUser(ctx, userpool.User{Username: "alice"}, userpool.WithPassword("Passw0rd!"), userpool.WithPermanentPassword()); err != nil {
+				t.Fatal(err)
+			}
+			if err := api.SetMFAConfiguration(id, tt.mfa); err != nil {
+				t.Fatal(err)
+			}
+			var current string
+			if tt.enroll {
+				current, err = api.EnableSoftwareTokenMFA(id, "alice")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			user := userpool.User{Username: "alice", Password: "Passw0rd!"}
+			secret, err := up.EnrollSoftwareToken(ctx, user, userpool.WithClientIDOrName(clientID), userpool.WithTOTPSecret(current))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if secret == "" || secret == current {
+				t.Errorf("got secret %q, want a new secret", secret)
+			}
+			mfa, err := up.UserMFA(ctx, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &userpool.MFA{Preferred: "SOFTWARE_TOKEN_MFA", Enabled: []string{"SOFTWARE_TOKEN_MFA"}}
+			if mfa == nil || mfa.Preferred != want.Preferred || !slices.Equal(mfa.Enabled, want.Enabled) {
+				t.Errorf("got %+v, want %+v", mfa, want)
+			}
+			if tt.mfa != types.UserPoolMfaTypeOff {
+				// the new secret answers the SOFTWARE_TOKEN_MFA challenge
+				if _, err := up.LoginAs(ctx, user, userpool.WithClientIDOrName(clientID), userpool.WithTOTPSecret(secret)); err != nil {
+					t.Error(err)
+				}
+			}
+
+			if err := up.DisableSoftwareTokenMFA(ctx, "alice"); err != nil {
+				t.Fatal(err)
+			}
+			mfa, err = up.UserMFA(ctx, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mfa != nil {
+				t.Errorf("got %+v, want no MFA", mfa)
+			}
+			if tt.mfa == types.UserPoolMfaTypeOptional {
+				// no MFA challenge after disabling
+				out, err := up.LoginAs(ctx, user, userpool.WithClientIDOrName(clientID))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if out.AuthenticationResult == nil {
+					t.Errorf("got %s challenge, want tokens", out.ChallengeName)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,513 @@
+package userpooltest
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/internal/jwt"
+	"github.com/k1LoW/coglet/internal/srp"
+	"github.com/k1LoW/coglet/internal/totp"
+)
+
+// challenge is an auth challenge in progress.
+type challenge struct {
+	name     types.ChallengeNameType
+	clientID string
+	username string
+
+	// PASSWORD_VERIFIER
+	srpA        *big.Int
+	srpB        *big.Int
+	srpb        *big.Int
+	verifier    *big.Int
+	secretBlock string
+
+	// MFA_SETUP
+	secretCode string
+	verified   bool
+}
+
+func (a *API) InitiateAuth(ctx context.Context, params *cognito.InitiateAuthInput, optFns ...func(*cognito.Options)) (*cognito.InitiateAuthOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, c, err := a.clientByID(params.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	switch params.AuthFlow {
+	case types.AuthFlowTypeUserPasswordAuth:
+		if !slices.Contains(c.authFlows, types.ExplicitAuthFlowsTypeAllowUserPasswordAuth) {
+			return nil, &types.InvalidParameterException{Message: aws.String("USER_PASSWORD_AUTH flow not enabled for this client")}
+		}
+		username := params.AuthParameters["USERNAME"]
+		if err := c.verifySecretHash(username, params.AuthParameters["SECRET_HASH"]); err != nil {
+			return nil, err
+		}
+		u, err := p.user(&username)
+		if err != nil || u.password != params.AuthParameters["PASSWORD"] {
+			return nil, &types.NotAuthorizedException{Message: aws.String("Incorrect username or password.")}
+		}
+		return p.authenticate(c, u)
+	case types.AuthFlowTypeUserSrpAuth:
+		if !slices.Contains(c.authFlows, types.ExplicitAuthFlowsTypeAllowUserSrpAuth) {
+			return nil, &types.InvalidParameterException{Message: aws.String("USER_SRP_AUTH flow not enabled for this client")}
+		}
+		username := params.AuthParameters["USERNAME"]
+		if err := c.verifySecretHash(username, params.AuthParameters["SECRET_HASH"]); err != nil {
+			return nil, err
+		}
+		u, err := p.user(&username)
+		if err != nil {
+			return nil, &types.NotAuthorizedException{Message: aws.String("Incorrect username or password.")}
+		}
+		return p.startSRP(c, u, params.AuthParameters["SRP_A"])
+	case types.AuthFlowTypeRefreshTokenAuth, types.AuthFlowTypeRefreshToken:
+		if !slices.Contains(c.authFlows, types.ExplicitAuthFlowsTypeAllowRefreshTokenAuth) {
+			return nil, &types.InvalidParameterException{Message: aws.String("REFRESH_TOKEN_AUTH flow not enabled for this client")}
+		}
+		username, ok := p.tokens[params.AuthParameters["REFRESH_TOKEN"]]
+		if !ok {
+			return nil, &types.NotAuthorizedException{Message: aws.String("Invalid Refresh Token")}
+		}
+		if err := c.verifySecretHash(username, params.AuthParameters["SECRET_HASH"]); err != nil {
+			return nil, err
+		}
+		u, err := p.user(&username)
+		if err != nil || !u.enabled {
+			return nil, &types.NotAuthorizedException{Message: aws.String("Refresh Token has been revoked")}
+		}
+		return &cognito.InitiateAuthOutput{
+			AuthenticationResult: p.authenticationResult(c, u, nil),
+		}, nil
+	default:
+		return nil, &types.InvalidParameterException{Message: aws.String(fmt.Sprintf("unsupported auth flow: %s", params.AuthFlow))}
+	}
+}
+
+func (a *API) RespondToAuthChallenge(ctx context.Context, params *cognito.RespondToAuthChallengeInput, optFns ...func(*cognito.Options)) (*cognito.RespondToAuthChallengeOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, c, err := a.clientByID(params.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	ch, ok := p.challenges[aws.ToString(params.Session)]
+	if !ok || ch.clientID != c.id || ch.name != params.ChallengeName {
+		return nil, &types.NotAuthorizedException{Message: aws.String("Invalid session for the user.")}
+	}
+	delete(p.challenges, aws.ToString(params.Session))
+	responses := params.ChallengeResponses
+	if responses["USERNAME"] != ch.username {
+		return nil, &types.NotAuthorizedException{Message: aws.String("Invalid session for the user.")}
+	}
+	if err := c.verifySecretHash(ch.username, responses["SECRET_HASH"]); err != nil {
+		return nil, err
+	}
+	u, err := p.user(&ch.username)
+	if err != nil {
+		return nil, &types.NotAuthorizedException{Message: aws.String("Incorrect username or password.")}
+	}
+
+	var out *cognito.InitiateAuthOutput
+	switch ch.name {
+	case types.ChallengeNameTypePasswordVerifier:
+		if err := ch.verifyPasswordClaim(p.id, u.username, responses); err != nil {
+			return nil, err
+		}
+		out, err = p.authenticate(c, u)
+	case types.ChallengeNameTypeNewPasswordRequired:
+		password := responses["NEW_PASSWORD"]
+		if err := p.validatePassword(password); err != nil {
+			return nil, err
+		}
+		var attrs []types.AttributeType
+		for k, v := range responses {
+			if name, ok := strings.CutPrefix(k, "userAttributes."); ok {
+				attrs = append(attrs, types.AttributeType{Name: aws.String(name), Value: aws.String(v)})
+			}
+		}
+		if err := u.setAttributes(attrs); err != nil {
+			return nil, err
+		}
+		for _, name := range p.requiredAttributes {
+			if _, ok := u.attributes[name]; !ok {
+				return nil, &types.InvalidParameterException{Message: aws.String(fmt.Sprintf("Missing required attribute: %s", name))}
+			}
+		}
+		u.password = password
+		u.status = types.UserStatusTypeConfirmed
+		u.modified = time.Now()
+		out, err = p.authenticate(c, u)
+	case types.ChallengeNameTypeSelectMfaType:
+		answer := responses["ANSWER"]
+		if !slices.Contains(u.mfaSettings, answer) {
+			return nil, &types.InvalidParameterException{Message: aws.String(fmt.Sprintf("MFA type is not enabled for the user: %s", answer))}
+		}
+		if answer != string(types.ChallengeNameTypeSoftwareTokenMfa) {
+			return nil, &types.InvalidParameterException{Message: aws.String(fmt.Sprintf("unsupported MFA type: %s", answer))}
+		}
+		out = p.newChallenge(types.ChallengeNameTypeSoftwareTokenMfa, c, u, map[string]string{
+			"USER_ID_FOR_SRP": u.username,
+		})
+	case types.ChallengeNameTypeSoftwareTokenMfa:
+		if !totp.Validate(u.totpSecret, responses["SOFTWARE_TOKEN_MFA_CODE"], time.Now()) {
+			return nil, &types.CodeMismatchException{Message: aws.String("Invalid code received for user")}
+		}
+		out = p.issue(c, u)
+	case types.ChallengeNameTypeMfaSetup:
+		if !ch.verified {
+			return nil, &types.InvalidParameterException{Message: aws.String("Software token has not been verified")}
+		}
+		out = p.issue(c, u)
+	default:
+		err = &types.InvalidParameterException{Message: aws.String(fmt.Sprintf("unsupported challenge: %s", ch.name))}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cognito.RespondToAuthChallengeOutput{
+		AuthenticationResult: out.AuthenticationResult,
+		ChallengeName:        out.ChallengeName,
+		ChallengeParameters:  out.ChallengeParameters,
+		Session:              out.Session,
+	}, nil
+}
+
+func (a *API) AssociateSoftwareToken(ctx context.Context, params *cognito.AssociateSoftwareTokenInput, optFns ...func(*cognito.Options)) (*cognito.AssociateSoftwareTokenOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if params.Session != nil {
+		_, ch, err := a.challenge(params.Session, types.ChallengeNameTypeMfaSetup)
+		if err != nil {
+			return nil, err
+		}
+		ch.secretCode = secret
+		return &cognito.AssociateSoftwareTokenOutput{SecretCode: aws.String(secret), Session: params.Session}, nil
+	}
+	_, u, err := a.userByAccessToken(params.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	u.pendingTOTPSecret = secret
+	return &cognito.AssociateSoftwareTokenOutput{SecretCode: aws.String(secret)}, nil
+}
+
+func (a *API) VerifySoftwareToken(ctx context.Context, params *cognito.VerifySoftwareTokenInput, optFns ...func(*cognito.Options)) (*cognito.VerifySoftwareTokenOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	mismatch := &types.EnableSoftwareTokenMFAException{Message: aws.String("Code mismatch")}
+	if params.Session != nil {
+		p, ch, err := a.challenge(params.Session, types.ChallengeNameTypeMfaSetup)
+		if err != nil {
+			return nil, err
+		}
+		if ch.secretCode == "" || !totp.Validate(ch.secretCode, aws.ToString(params.UserCode), time.Now()) {
+			return nil, mismatch
+		}
+		u, err := p.user(&ch.username)
+		if err != nil {
+			return nil, err
+		}
+		u.totpSecret = ch.secretCode
+		u.enableSoftwareTokenMFA(true)
+		ch.verified = true
+		return &cognito.VerifySoftwareTokenOutput{Status: types.VerifySoftwareTokenResponseTypeSuccess, Session: params.Session}, nil
+	}
+	_, u, err := a.userByAccessToken(params.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	if u.pendingTOTPSecret == "" || !totp.Validate(u.pendingTOTPSecret, aws.ToString(params.UserCode), time.Now()) {
+		return nil, mismatch
+	}
+	u.totpSecret = u.pendingTOTPSecret
+	u.pendingTOTPSecret = ""
+	return &cognito.VerifySoftwareTokenOutput{Status: types.VerifySoftwareTokenResponseTypeSuccess}, nil
+}
+
+func (a *API) challenge(session *string, name types.ChallengeNameType) (*pool, *challenge, error) {
+	for _, p := range a.pools {
+		if ch, ok := p.challenges[aws.ToString(session)]; ok && ch.name == name {
+			return p, ch, nil
+		}
+	}
+	return nil, nil, &types.NotAuthorizedException{Message: aws.String("Invalid session for the user.")}
+}
+
+func (a *API) userByAccessToken(token *string) (*pool, *user, error) {
+	for _, p := range a.pools {
+		if username, ok := p.accessTokens[aws.ToString(token)]; ok {
+			u, err := p.user(&username)
+			if err != nil {
+				break
+			}
+			return p, u, nil
+		}
+	}
+	return nil, nil, &types.NotAuthorizedException{Message: aws.String("Invalid Access Token")}
+}
+
+// authenticate returns tokens, or the next challenge for the user who has proved the password.
+func (p *pool) authenticate(c *client, u *user) (*cognito.InitiateAuthOutput, error) {
+	if !u.enabled {
+		return nil, &types.NotAuthorizedException{Message: aws.String("User is disabled.")}
+	}
+	switch u.status {
+	case types.UserStatusTypeForceChangePassword:
+		attrs, err := json.Marshal(u.attributes)
+		if err != nil {
+			return nil, err
+		}
+		required := []string{}
+		for _, name := range p.requiredAttributes {
+			if _, ok := u.attributes[name]; !ok {
+				required = append(required, "userAttributes."+name)
+			}
+		}
+		requiredJSON, err := json.Marshal(required)
+		if err != nil {
+			return nil, err
+		}
+		return p.newChallenge(types.ChallengeNameTypeNewPasswordRequired, c, u, map[string]string{
+			"USER_ID_FOR_SRP":    u.username,
+			"requiredAttributes": string(requiredJSON),
+			"userAttributes":     string(attrs),
+		}), nil
+	case types.UserStatusTypeResetRequired:
+		return nil, &types.PasswordResetRequiredException{Message: aws.String("Password reset required for the user")}
+	}
+	if p.mfa != types.UserPoolMfaTypeOff && p.mfa != "" {
+		switch {
+		case len(u.mfaSettings) > 1 && u.preferredMFA == "":
+			choices, err := json.Marshal(u.mfaSettings)
+			if err != nil {
+				return nil, err
+			}
+			return p.newChallenge(types.ChallengeNameTypeSelectMfaType, c, u, map[string]string{
+				"USER_ID_FOR_SRP": u.username,
+				"MFAS_CAN_CHOOSE": string(choices),
+			}), nil
+		case slices.Contains(u.mfaSettings, string(types.ChallengeNameTypeSoftwareTokenMfa)):
+			return p.newChallenge(types.ChallengeNameTypeSoftwareTokenMfa, c, u, map[string]string{
+				"USER_ID_FOR_SRP": u.username,
+			}), nil
+		case p.mfa == types.UserPoolMfaTypeOn:
+			return p.newChallenge(types.ChallengeNameTypeMfaSetup, c, u, map[string]string{
+				"USER_ID_FOR_SRP": u.username,
+				"MFAS_CAN_SETUP":  `["SOFTWARE_TOKEN_MFA"]`,
+			}), nil
+		}
+	}
+	return p.issue(c, u), nil
+}
+
+// issue returns tokens for the authenticated user.
+func (p *pool) issue(c *client, u *user) *cognito.InitiateAuthOutput {
+	refreshToken := randomString(64)
+	p.tokens[refreshToken] = u.username
+	return &cognito.InitiateAuthOutput{
+		AuthenticationResult: p.authenticationResult(c, u, aws.String(refreshToken)),
+	}
+}
+
+func (p *pool) newChallenge(name types.ChallengeNameType, c *client, u *user, params map[string]string) *cognito.InitiateAuthOutput {
+	session := randomString(64)
+	p.challenges[session] = &challenge{
+		name:     name,
+		clientID: c.id,
+		username: u.username,
+	}
+	return &cognito.InitiateAuthOutput{
+		ChallengeName:       name,
+		Session:             aws.String(session),
+		ChallengeParameters: params,
+	}
+}
+
+// startSRP returns the PASSWORD_VERIFIER challenge for SRP_A.
+func (p *pool) startSRP(c *client, u *user, srpA string) (*cognito.InitiateAuthOutput, error) {
+	A, ok := new(big.Int).SetString(srpA, 16)
+	if !ok || new(big.Int).Mod(A, srp.N).Sign() == 0 {
+		return nil, &types.InvalidParameterException{Message: aws.String("SRP_A is invalid")}
+	}
+	salt, err := srp.RandomInt()
+	if err != nil {
+		return nil, err
+	}
+	b, err := srp.RandomInt()
+	if err != nil {
+		return nil, err
+	}
+	// v = g^x % N, B = (k * v + g^b) % N
+	v := new(big.Int).Exp(srp.G, srp.X(salt, srp.PoolName(p.id), u.username, u.password), srp.N)
+	B := new(big.Int).Add(new(big.Int).Mul(srp.K, v), new(big.Int).Exp(srp.G, b, srp.N))
+	B.Mod(B, srp.N)
+	block := make([]byte, 64)
+	if _, err := rand.Read(block); err != nil {
+		return nil, err
+	}
+	ch := &challenge{
+		name:        types.ChallengeNameTypePasswordVerifier,
+		clientID:    c.id,
+		username:    u.username,
+		srpA:        A,
+		srpB:        B,
+		srpb:        b,
+		verifier:    v,
+		secretBlock: base64.StdEncoding.EncodeToString(block),
+	}
+	session := randomString(64)
+	p.challenges[session] = ch
+	return &cognito.InitiateAuthOutput{
+		ChallengeName: ch.name,
+		Session:       aws.String(session),
+		ChallengeParameters: map[string]string{
+			"USER_ID_FOR_SRP": u.username,
+			"USERNAME":        u.username,
+			"SALT":            salt.Text(16),
+			"SRP_B":           B.Text(16),
+			"SECRET_BLOCK":    ch.secretBlock,
+		},
+	}, nil
+}
+
+func (ch *challenge) verifyPasswordClaim(userPoolID, username string, responses map[string]string) error {
+	if responses["PASSWORD_CLAIM_SECRET_BLOCK"] != ch.secretBlock {
+		return &types.NotAuthorizedException{Message: aws.String("Incorrect username or password.")}
+	}
+	if _, err := time.Parse(srp.TimestampFormat, responses["TIMESTAMP"]); err != nil {
+		return &types.InvalidParameterException{Message: aws.String("TIMESTAMP is invalid")}
+	}
+	// S = (A * v^u) ^ b % N
+	u := srp.U(ch.srpA, ch.srpB)
+	S := new(big.Int).Mul(ch.srpA, new(big.Int).Exp(ch.verifier, u, srp.N))
+	S.Exp(S, ch.srpb, srp.N)
+	expected, err := srp.Signature(srp.DerivedKey(S, u), srp.PoolName(userPoolID), username, ch.secretBlock, responses["TIMESTAMP"])
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(responses["PASSWORD_CLAIM_SIGNATURE"])) {
+		return &types.NotAuthorizedException{Message: aws.String("Incorrect username or password.")}
+	}
+	return nil
+}
+
+func (p *pool) authenticationResult(c *client, u *user, refreshToken *string) *types.AuthenticationResultType {
+	now := time.Now()
+	iss := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, p.id)
+	idClaims := map[string]any{
+		"sub":              u.attributes["sub"],
+		"aud":              c.id,
+		"iss":              iss,
+		"token_use":        "id",
+		"cognito:username": u.username,
+		"auth_time":        now.Unix(),
+		"iat":              now.Unix(),
+		"exp":              now.Unix() + tokenExpiresIn,
+	}
+	for k, v := range u.attributes {
+		if k == "email_verified" || k == "phone_number_verified" {
+			b, _ := strconv.ParseBool(v)
+			idClaims[k] = b
+			continue
+		}
+		idClaims[k] = v
+	}
+	if len(u.groups) > 0 {
+		idClaims["cognito:groups"] = slices.Clone(u.groups)
+	}
+	accessClaims := map[string]any{
+		"sub":       u.attributes["sub"],
+		"client_id": c.id,
+		"iss":       iss,
+		"token_use": "access",
+		"scope":     "aws.cognito.signin.user.admin",
+		"username":  u.username,
+		"auth_time": now.Unix(),
+		"iat":       now.Unix(),
+		"exp":       now.Unix() + tokenExpiresIn,
+	}
+	if len(u.groups) > 0 {
+		accessClaims["cognito:groups"] = slices.Clone(u.groups)
+	}
+	accessToken := token(accessClaims)
+	p.accessTokens[accessToken] = u.username
+	return &types.AuthenticationResultType{
+		AccessToken:  aws.String(accessToken),
+		IdToken:      aws.String(token(idClaims)),
+		RefreshToken: refreshToken,
+		ExpiresIn:    tokenExpiresIn,
+		TokenType:    aws.String("Bearer"),
+	}
+}
+
+func (c *client) verifySecretHash(username, hash string) error {
+	if c.secret == "" {
+		return nil
+	}
+	h := hmac.New(sha256.New, []byte(c.secret))
+	h.Write([]byte(username + c.id))
+	if hash != base64.StdEncoding.EncodeToString(h.Sum(nil)) {
+		return &types.NotAuthorizedException{Message: aws.String(fmt.Sprintf("Unable to verify secret hash for client %s", c.id))}
+	}
+	return nil
+}
+
+const signingKeyID = "userpooltest"
+
+// signingKey is the key to sign tokens, shared by all user pools of the fake.
+var signingKey = sync.OnceValue(func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+})
+
+// JWKS returns the JSON Web Key Set to verify the tokens issued by the fake.
+func (a *API) JWKS() []byte {
+	b, _ := json.Marshal(jwt.JWKS{Keys: []jwt.JWK{jwt.NewJWK(signingKeyID, &signingKey().PublicKey)}})
+	return b
+}
+
+func token(claims map[string]any) string {
+	enc := base64.RawURLEncoding
+	h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": signingKeyID})
+	c, _ := json.Marshal(claims)
+	signed := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, signingKey(), crypto.SHA256, sum[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + enc.EncodeToString(sig)
+}
+
+func newTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
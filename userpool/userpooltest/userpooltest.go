@@ -0,0 +1,1058 @@
+// Package userpooltest provides an in-memory implementation of userpool.API for testing.
+package userpooltest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cognito "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/k1LoW/coglet/userpool"
+)
+
+const (
+	region          = "local"
+	tokenExpiresIn  = 3600
+	defaultMaxItems = 60
+)
+
+var _ userpool.API = (*API)(nil)
+
+// API is an in-memory fake of the Amazon Cognito Identity Provider API.
+// It is safe for concurrent use.
+type API struct {
+	mu    sync.Mutex
+	pools []*pool
+}
+
+// User is a snapshot of a user stored in the fake.
+type User struct {
+	Username   string
+	Password   string
+	Attributes map[string]string
+	Groups     []string
+	Enabled    bool
+	Status     types.UserStatusType
+}
+
+type pool struct {
+	id      string
+	name    string
+	policy  types.PasswordPolicyType
+	clients []*client
+	users   []*user
+	groups  []*group
+	tokens  map[string]string // refresh token -> username
+	// challenges in progress by session
+	challenges map[string]*challenge
+	// attributes required on setting a new password
+	requiredAttributes []string
+	// attributes that can be set only on creation, in addition to sub
+	immutableAttributes []string
+	// attributes used as usernames, such as email and phone_number
+	usernameAttributes []types.UsernameAttributeType
+	mfa                types.UserPoolMfaType
+	accessTokens       map[string]string // access token -> username
+}
+
+type group struct {
+	name        string
+	description *string
+	precedence  *int32
+	roleArn     *string
+	created     time.Time
+	modified    time.Time
+}
+
+type client struct {
+	id        string
+	name      string
+	secret    string
+	authFlows []types.ExplicitAuthFlowsType
+}
+
+type user struct {
+	username   string
+	password   string
+	attributes map[string]string
+	groups     []string
+	// verified software token
+	totpSecret        string
+	pendingTOTPSecret string
+	mfaSettings       []string
+	preferredMFA      string
+	enabled           bool
+	status            types.UserStatusType
+	created           time.Time
+	modified          time.Time
+}
+
+// NewAPI returns an empty fake.
+func NewAPI() *API {
+	return &API{}
+}
+
+// CreateUserPool creates a user pool with a default password policy and returns its ID.
+func (a *API) CreateUserPool(name string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p := &pool{
+		id:   fmt.Sprintf("%s_%s", region, randomString(9)),
+		name: name,
+		policy: types.PasswordPolicyType{
+			MinimumLength:    aws.Int32(8),
+			RequireLowercase: true,
+			RequireNumbers:   true,
+			RequireSymbols:   true,
+			RequireUppercase: true,
+		},
+		tokens:       map[string]string{},
+		challenges:   map[string]*challenge{},
+		mfa:          types.UserPoolMfaTypeOff,
+		accessTokens: map[string]string{},
+	}
+	a.pools = append(a.pools, p)
+	return p.id
+}
+
+// SetPasswordPolicy replaces the password policy of the user pool.
+func (a *API) SetPasswordPolicy(userPoolID string, policy types.PasswordPolicyType) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return err
+	}
+	p.policy = policy
+	return nil
+}
+
+// SetRequiredAttributes sets the attributes that users must have.
+// Users without them are asked for them in the NEW_PASSWORD_REQUIRED challenge.
+func (a *API) SetRequiredAttributes(userPoolID string, names ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return err
+	}
+	p.requiredAttributes = names
+	return nil
+}
+
+// SetUsernameAttributes sets the attributes that users sign in with instead of a username.
+// Users created afterwards get their sub as the username, and can be referred to by the attributes or the sub.
+func (a *API) SetUsernameAttributes(userPoolID string, attrs ...types.UsernameAttributeType) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return err
+	}
+	p.usernameAttributes = attrs
+	return nil
+}
+
+// SetImmutableAttributes sets the attributes that can be set only on creation, such as immutable custom attributes.
+func (a *API) SetImmutableAttributes(userPoolID string, names ...string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return err
+	}
+	p.immutableAttributes = names
+	return nil
+}
+
+// SetUserAttributes sets the attributes of the user directly, including read-only attributes such as identities of federated users.
+func (a *API) SetUserAttributes(userPoolID, username string, attrs map[string]string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return err
+	}
+	u, err := p.user(&username)
+	if err != nil {
+		return err
+	}
+	maps.Copy(u.attributes, attrs)
+	return nil
+}
+
+// SetMFAConfiguration sets the MFA configuration of the user pool.
+// Only software token MFA is modeled.
+func (a *API) SetMFAConfiguration(userPoolID string, mfa types.UserPoolMfaType) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return err
+	}
+	p.mfa = mfa
+	return nil
+}
+
+// EnableSoftwareTokenMFA registers a verified software token for the user, makes it preferred and returns its secret.
+func (a *API) EnableSoftwareTokenMFA(userPoolID, username string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return "", err
+	}
+	u, err := p.user(&username)
+	if err != nil {
+		return "", err
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	u.totpSecret = secret
+	u.enableSoftwareTokenMFA(true)
+	return secret, nil
+}
+
+// CreateUserPoolClient creates an app client in the user pool and returns its ID.
+// The client allows USER_PASSWORD_AUTH, USER_SRP_AUTH and REFRESH_TOKEN_AUTH.
+func (a *API) CreateUserPoolClient(userPoolID, name string, generateSecret bool) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return "", err
+	}
+	c := &client{
+		id:   randomString(26),
+		name: name,
+		authFlows: []types.ExplicitAuthFlowsType{
+			types.ExplicitAuthFlowsTypeAllowUserPasswordAuth,
+			types.ExplicitAuthFlowsTypeAllowUserSrpAuth,
+			types.ExplicitAuthFlowsTypeAllowRefreshTokenAuth,
+		},
+	}
+	if generateSecret {
+		c.secret = randomString(51)
+	}
+	p.clients = append(p.clients, c)
+	return c.id, nil
+}
+
+// SetExplicitAuthFlows replaces the auth flows allowed for the app client.
+func (a *API) SetExplicitAuthFlows(userPoolID, clientID string, flows ...types.ExplicitAuthFlowsType) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return err
+	}
+	c, err := p.client(&clientID)
+	if err != nil {
+		return err
+	}
+	c.authFlows = flows
+	return nil
+}
+
+// GetUser returns a snapshot of the user.
+func (a *API) GetUser(userPoolID, username string) (User, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(&userPoolID)
+	if err != nil {
+		return User{}, false
+	}
+	u, err := p.user(&username)
+	if err != nil {
+		return User{}, false
+	}
+	return User{
+		Username:   u.username,
+		Password:   u.password,
+		Attributes: maps.Clone(u.attributes),
+		Groups:     slices.Clone(u.groups),
+		Enabled:    u.enabled,
+		Status:     u.status,
+	}, true
+}
+
+func (a *API) AdminGetUser(ctx context.Context, params *cognito.AdminGetUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminGetUserOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	return &cognito.AdminGetUserOutput{
+		Username:             aws.String(u.username),
+		UserAttributes:       u.attributeTypes(),
+		UserMFASettingList:   slices.Clone(u.mfaSettings),
+		PreferredMfaSetting:  optionalString(u.preferredMFA),
+		Enabled:              u.enabled,
+		UserStatus:           u.status,
+		UserCreateDate:       aws.Time(u.created),
+		UserLastModifiedDate: aws.Time(u.modified),
+	}, nil
+}
+
+func (a *API) AdminCreateUser(ctx context.Context, params *cognito.AdminCreateUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminCreateUserOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	if aws.ToString(params.Username) == "" {
+		return nil, &types.InvalidParameterException{Message: aws.String("Username is required")}
+	}
+	if _, err := p.user(params.Username); err == nil {
+		return nil, &types.UsernameExistsException{Message: aws.String("User account already exists")}
+	}
+	password := aws.ToString(params.TemporaryPassword)
+	if password == "" {
+		password = "Tmp1!" + randomString(16)
+	} else if err := p.validatePassword(password); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sub := uuid()
+	u := &user{
+		username:   aws.ToString(params.Username),
+		password:   password,
+		attributes: map[string]string{"sub": sub},
+		enabled:    true,
+		status:     types.UserStatusTypeForceChangePassword,
+		created:    now,
+		modified:   now,
+	}
+	if err := u.setAttributes(params.UserAttributes); err != nil {
+		return nil, err
+	}
+	if len(p.usernameAttributes) > 0 {
+		attr, err := p.usernameAttribute(u.username)
+		if err != nil {
+			return nil, err
+		}
+		u.attributes[attr] = u.username
+		u.username = sub
+	}
+	p.users = append(p.users, u)
+	return &cognito.AdminCreateUserOutput{
+		User: u.userType(),
+	}, nil
+}
+
+func (a *API) AdminUpdateUserAttributes(ctx context.Context, params *cognito.AdminUpdateUserAttributesInput, optFns ...func(*cognito.Options)) (*cognito.AdminUpdateUserAttributesOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	for _, attr := range params.UserAttributes {
+		name := aws.ToString(attr.Name)
+		if slices.Contains(p.immutableAttributes, name) {
+			return nil, &types.InvalidParameterException{Message: aws.String("Cannot modify an immutable attribute: " + name)}
+		}
+		if name == "identities" || strings.HasPrefix(name, "cognito:") {
+			return nil, &types.InvalidParameterException{Message: aws.String("Cannot modify the non-mutable attribute: " + name)}
+		}
+	}
+	if err := u.setAttributes(params.UserAttributes); err != nil {
+		return nil, err
+	}
+	u.modified = time.Now()
+	return &cognito.AdminUpdateUserAttributesOutput{}, nil
+}
+
+func (a *API) AdminSetUserPassword(ctx context.Context, params *cognito.AdminSetUserPasswordInput, optFns ...func(*cognito.Options)) (*cognito.AdminSetUserPasswordOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	password := aws.ToString(params.Password)
+	if err := p.validatePassword(password); err != nil {
+		return nil, err
+	}
+	u.password = password
+	if params.Permanent {
+		u.status = types.UserStatusTypeConfirmed
+	} else {
+		u.status = types.UserStatusTypeForceChangePassword
+	}
+	u.modified = time.Now()
+	return &cognito.AdminSetUserPasswordOutput{}, nil
+}
+
+func (a *API) CreateGroup(ctx context.Context, params *cognito.CreateGroupInput, optFns ...func(*cognito.Options)) (*cognito.CreateGroupOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	if aws.ToString(params.GroupName) == "" {
+		return nil, &types.InvalidParameterException{Message: aws.String("GroupName is required")}
+	}
+	if _, err := p.group(params.GroupName); err == nil {
+		return nil, &types.GroupExistsException{Message: aws.String("A group with the name already exists.")}
+	}
+	now := time.Now()
+	g := &group{
+		name:        aws.ToString(params.GroupName),
+		description: params.Description,
+		precedence:  params.Precedence,
+		roleArn:     params.RoleArn,
+		created:     now,
+		modified:    now,
+	}
+	p.groups = append(p.groups, g)
+	return &cognito.CreateGroupOutput{Group: g.groupType(p.id)}, nil
+}
+
+func (a *API) GetGroup(ctx context.Context, params *cognito.GetGroupInput, optFns ...func(*cognito.Options)) (*cognito.GetGroupOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	g, err := p.group(params.GroupName)
+	if err != nil {
+		return nil, err
+	}
+	return &cognito.GetGroupOutput{Group: g.groupType(p.id)}, nil
+}
+
+func (a *API) UpdateGroup(ctx context.Context, params *cognito.UpdateGroupInput, optFns ...func(*cognito.Options)) (*cognito.UpdateGroupOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	g, err := p.group(params.GroupName)
+	if err != nil {
+		return nil, err
+	}
+	g.description = params.Description
+	g.precedence = params.Precedence
+	g.roleArn = params.RoleArn
+	g.modified = time.Now()
+	return &cognito.UpdateGroupOutput{Group: g.groupType(p.id)}, nil
+}
+
+func (a *API) DeleteGroup(ctx context.Context, params *cognito.DeleteGroupInput, optFns ...func(*cognito.Options)) (*cognito.DeleteGroupOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	g, err := p.group(params.GroupName)
+	if err != nil {
+		return nil, err
+	}
+	p.groups = slices.DeleteFunc(p.groups, func(v *group) bool { return v == g })
+	for _, u := range p.users {
+		u.groups = slices.DeleteFunc(u.groups, func(name string) bool { return name == g.name })
+	}
+	return &cognito.DeleteGroupOutput{}, nil
+}
+
+func (a *API) ListGroups(ctx context.Context, params *cognito.ListGroupsInput, optFns ...func(*cognito.Options)) (*cognito.ListGroupsOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	start, end, next, err := page(len(p.groups), params.Limit, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	out := &cognito.ListGroupsOutput{NextToken: next}
+	for _, g := range p.groups[start:end] {
+		out.Groups = append(out.Groups, *g.groupType(p.id))
+	}
+	return out, nil
+}
+
+func (a *API) AdminAddUserToGroup(ctx context.Context, params *cognito.AdminAddUserToGroupInput, optFns ...func(*cognito.Options)) (*cognito.AdminAddUserToGroupOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	g, err := p.group(params.GroupName)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(u.groups, g.name) {
+		u.groups = append(u.groups, g.name)
+	}
+	return &cognito.AdminAddUserToGroupOutput{}, nil
+}
+
+func (a *API) AdminRemoveUserFromGroup(ctx context.Context, params *cognito.AdminRemoveUserFromGroupInput, optFns ...func(*cognito.Options)) (*cognito.AdminRemoveUserFromGroupOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	g, err := p.group(params.GroupName)
+	if err != nil {
+		return nil, err
+	}
+	u.groups = slices.DeleteFunc(u.groups, func(name string) bool { return name == g.name })
+	return &cognito.AdminRemoveUserFromGroupOutput{}, nil
+}
+
+func (a *API) AdminListGroupsForUser(ctx context.Context, params *cognito.AdminListGroupsForUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminListGroupsForUserOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	start, end, next, err := page(len(u.groups), params.Limit, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	out := &cognito.AdminListGroupsForUserOutput{NextToken: next}
+	for _, name := range u.groups[start:end] {
+		g, err := p.group(&name)
+		if err != nil {
+			return nil, err
+		}
+		out.Groups = append(out.Groups, *g.groupType(p.id))
+	}
+	return out, nil
+}
+
+func (a *API) AdminDeleteUser(ctx context.Context, params *cognito.AdminDeleteUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminDeleteUserOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	p.users = slices.DeleteFunc(p.users, func(v *user) bool { return v == u })
+	maps.DeleteFunc(p.tokens, func(_, username string) bool { return username == u.username })
+	return &cognito.AdminDeleteUserOutput{}, nil
+}
+
+func (a *API) AdminDisableUser(ctx context.Context, params *cognito.AdminDisableUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminDisableUserOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	u.enabled = false
+	u.modified = time.Now()
+	return &cognito.AdminDisableUserOutput{}, nil
+}
+
+// AdminSetUserMFAPreference sets the MFA preference of the user.
+// Only software token MFA is modeled. SMS MFA can be enabled, but its challenge cannot be answered.
+func (a *API) AdminSetUserMFAPreference(ctx context.Context, params *cognito.AdminSetUserMFAPreferenceInput, optFns ...func(*cognito.Options)) (*cognito.AdminSetUserMFAPreferenceOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	if s := params.SoftwareTokenMfaSettings; s != nil {
+		const name = string(types.ChallengeNameTypeSoftwareTokenMfa)
+		switch {
+		case s.Enabled:
+			if u.totpSecret == "" {
+				return nil, &types.InvalidParameterException{Message: aws.String("User has not verified software token mfa")}
+			}
+			u.enableSoftwareTokenMFA(s.PreferredMfa)
+		default:
+			u.mfaSettings = slices.DeleteFunc(u.mfaSettings, func(v string) bool { return v == name })
+			if u.preferredMFA == name {
+				u.preferredMFA = ""
+			}
+		}
+		u.modified = time.Now()
+	}
+	if s := params.SMSMfaSettings; s != nil {
+		const name = string(types.ChallengeNameTypeSmsMfa)
+		switch {
+		case s.Enabled:
+			if !slices.Contains(u.mfaSettings, name) {
+				u.mfaSettings = append(u.mfaSettings, name)
+			}
+			if s.PreferredMfa {
+				u.preferredMFA = name
+			}
+		default:
+			u.mfaSettings = slices.DeleteFunc(u.mfaSettings, func(v string) bool { return v == name })
+			if u.preferredMFA == name {
+				u.preferredMFA = ""
+			}
+		}
+		u.modified = time.Now()
+	}
+	return &cognito.AdminSetUserMFAPreferenceOutput{}, nil
+}
+
+func (a *API) AdminEnableUser(ctx context.Context, params *cognito.AdminEnableUserInput, optFns ...func(*cognito.Options)) (*cognito.AdminEnableUserOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	u.enabled = true
+	u.modified = time.Now()
+	return &cognito.AdminEnableUserOutput{}, nil
+}
+
+func (a *API) AdminResetUserPassword(ctx context.Context, params *cognito.AdminResetUserPasswordInput, optFns ...func(*cognito.Options)) (*cognito.AdminResetUserPasswordOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	u, err := p.user(params.Username)
+	if err != nil {
+		return nil, err
+	}
+	u.status = types.UserStatusTypeResetRequired
+	u.modified = time.Now()
+	return &cognito.AdminResetUserPasswordOutput{}, nil
+}
+
+func (a *API) DescribeUserPool(ctx context.Context, params *cognito.DescribeUserPoolInput, optFns ...func(*cognito.Options)) (*cognito.DescribeUserPoolOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	policy := p.policy
+	schema := []types.SchemaAttributeType{
+		{Name: aws.String("sub"), AttributeDataType: types.AttributeDataTypeString, Mutable: aws.Bool(false)},
+		{Name: aws.String("identities"), AttributeDataType: types.AttributeDataTypeString, Mutable: aws.Bool(true)},
+	}
+	for _, name := range p.immutableAttributes {
+		schema = append(schema, types.SchemaAttributeType{Name: aws.String(name), AttributeDataType: types.AttributeDataTypeString, Mutable: aws.Bool(false)})
+	}
+	return &cognito.DescribeUserPoolOutput{
+		UserPool: &types.UserPoolType{
+			Id:                     aws.String(p.id),
+			Name:                   aws.String(p.name),
+			EstimatedNumberOfUsers: int32(len(p.users)), //nolint:gosec
+			Policies: &types.UserPoolPolicyType{
+				PasswordPolicy: &policy,
+			},
+			SchemaAttributes:   schema,
+			UsernameAttributes: slices.Clone(p.usernameAttributes),
+		},
+	}, nil
+}
+
+func (a *API) ListUsers(ctx context.Context, params *cognito.ListUsersInput, optFns ...func(*cognito.Options)) (*cognito.ListUsersOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	match, err := parseFilter(aws.ToString(params.Filter))
+	if err != nil {
+		return nil, err
+	}
+	var users []*user
+	for _, u := range p.users {
+		if match(u) {
+			users = append(users, u)
+		}
+	}
+	start, end, next, err := page(len(users), params.Limit, params.PaginationToken)
+	if err != nil {
+		return nil, err
+	}
+	out := &cognito.ListUsersOutput{PaginationToken: next}
+	for _, u := range users[start:end] {
+		ut := u.userType()
+		if len(params.AttributesToGet) > 0 {
+			ut.Attributes = slices.DeleteFunc(ut.Attributes, func(attr types.AttributeType) bool {
+				return !slices.Contains(params.AttributesToGet, aws.ToString(attr.Name))
+			})
+		}
+		out.Users = append(out.Users, *ut)
+	}
+	return out, nil
+}
+
+func (a *API) ListUserPools(ctx context.Context, params *cognito.ListUserPoolsInput, optFns ...func(*cognito.Options)) (*cognito.ListUserPoolsOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	start, end, next, err := page(len(a.pools), params.MaxResults, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	out := &cognito.ListUserPoolsOutput{NextToken: next}
+	for _, p := range a.pools[start:end] {
+		out.UserPools = append(out.UserPools, types.UserPoolDescriptionType{
+			Id:   aws.String(p.id),
+			Name: aws.String(p.name),
+		})
+	}
+	return out, nil
+}
+
+func (a *API) ListUserPoolClients(ctx context.Context, params *cognito.ListUserPoolClientsInput, optFns ...func(*cognito.Options)) (*cognito.ListUserPoolClientsOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	start, end, next, err := page(len(p.clients), params.MaxResults, params.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	out := &cognito.ListUserPoolClientsOutput{NextToken: next}
+	for _, c := range p.clients[start:end] {
+		out.UserPoolClients = append(out.UserPoolClients, types.UserPoolClientDescription{
+			ClientId:   aws.String(c.id),
+			ClientName: aws.String(c.name),
+			UserPoolId: aws.String(p.id),
+		})
+	}
+	return out, nil
+}
+
+func (a *API) DescribeUserPoolClient(ctx context.Context, params *cognito.DescribeUserPoolClientInput, optFns ...func(*cognito.Options)) (*cognito.DescribeUserPoolClientOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, err := a.pool(params.UserPoolId)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.client(params.ClientId)
+	if err != nil {
+		return nil, err
+	}
+	uc := &types.UserPoolClientType{
+		ClientId:          aws.String(c.id),
+		ClientName:        aws.String(c.name),
+		UserPoolId:        aws.String(p.id),
+		ExplicitAuthFlows: slices.Clone(c.authFlows),
+	}
+	if c.secret != "" {
+		uc.ClientSecret = aws.String(c.secret)
+	}
+	return &cognito.DescribeUserPoolClientOutput{UserPoolClient: uc}, nil
+}
+
+func (a *API) pool(id *string) (*pool, error) {
+	for _, p := range a.pools {
+		if p.id == aws.ToString(id) {
+			return p, nil
+		}
+	}
+	return nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("User pool %s does not exist.", aws.ToString(id)))}
+}
+
+func (a *API) clientByID(id *string) (*pool, *client, error) {
+	for _, p := range a.pools {
+		if c, err := p.client(id); err == nil {
+			return p, c, nil
+		}
+	}
+	return nil, nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("User pool client %s does not exist.", aws.ToString(id)))}
+}
+
+func (p *pool) client(id *string) (*client, error) {
+	for _, c := range p.clients {
+		if c.id == aws.ToString(id) {
+			return c, nil
+		}
+	}
+	return nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("User pool client %s does not exist.", aws.ToString(id)))}
+}
+
+func (p *pool) user(username *string) (*user, error) {
+	name := aws.ToString(username)
+	for _, u := range p.users {
+		if u.username == name {
+			return u, nil
+		}
+	}
+	if len(p.usernameAttributes) > 0 {
+		for _, u := range p.users {
+			if u.attributes["sub"] == name {
+				return u, nil
+			}
+			for _, attr := range p.usernameAttributes {
+				if v, ok := u.attributes[string(attr)]; ok && v == name {
+					return u, nil
+				}
+			}
+		}
+	}
+	return nil, &types.UserNotFoundException{Message: aws.String("User does not exist.")}
+}
+
+// usernameAttribute returns the username attribute that username is a value of.
+func (p *pool) usernameAttribute(username string) (string, error) {
+	switch {
+	case strings.Contains(username, "@") && slices.Contains(p.usernameAttributes, types.UsernameAttributeTypeEmail):
+		return string(types.UsernameAttributeTypeEmail), nil
+	case strings.HasPrefix(username, "+") && slices.Contains(p.usernameAttributes, types.UsernameAttributeTypePhoneNumber):
+		return string(types.UsernameAttributeTypePhoneNumber), nil
+	default:
+		return "", &types.InvalidParameterException{Message: aws.String("Username should be either an email or a phone number.")}
+	}
+}
+
+func (p *pool) group(name *string) (*group, error) {
+	for _, g := range p.groups {
+		if g.name == aws.ToString(name) {
+			return g, nil
+		}
+	}
+	return nil, &types.ResourceNotFoundException{Message: aws.String("Group not found.")}
+}
+
+func (p *pool) validatePassword(password string) error {
+	invalid := func(msg string) error {
+		return &types.InvalidPasswordException{Message: aws.String("Password does not conform to policy: " + msg)}
+	}
+	if len(password) < int(aws.ToInt32(p.policy.MinimumLength)) {
+		return invalid("Password not long enough")
+	}
+	var lower, upper, number, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			number = true
+		default:
+			symbol = true
+		}
+	}
+	switch {
+	case p.policy.RequireLowercase && !lower:
+		return invalid("Password must have lowercase characters")
+	case p.policy.RequireUppercase && !upper:
+		return invalid("Password must have uppercase characters")
+	case p.policy.RequireNumbers && !number:
+		return invalid("Password must have numeric characters")
+	case p.policy.RequireSymbols && !symbol:
+		return invalid("Password must have symbol characters")
+	}
+	return nil
+}
+
+func (g *group) groupType(userPoolID string) *types.GroupType {
+	return &types.GroupType{
+		GroupName:        aws.String(g.name),
+		Description:      g.description,
+		Precedence:       g.precedence,
+		RoleArn:          g.roleArn,
+		UserPoolId:       aws.String(userPoolID),
+		CreationDate:     aws.Time(g.created),
+		LastModifiedDate: aws.Time(g.modified),
+	}
+}
+
+func (u *user) setAttributes(attrs []types.AttributeType) error {
+	for _, attr := range attrs {
+		name := aws.ToString(attr.Name)
+		if name == "sub" {
+			return &types.InvalidParameterException{Message: aws.String("Cannot modify an immutable attribute: sub")}
+		}
+		u.attributes[name] = aws.ToString(attr.Value)
+	}
+	return nil
+}
+
+func (u *user) enableSoftwareTokenMFA(preferred bool) {
+	const name = string(types.ChallengeNameTypeSoftwareTokenMfa)
+	if !slices.Contains(u.mfaSettings, name) {
+		u.mfaSettings = append(u.mfaSettings, name)
+	}
+	switch {
+	case preferred:
+		u.preferredMFA = name
+	case u.preferredMFA == name:
+		u.preferredMFA = ""
+	}
+}
+
+func (u *user) attributeTypes() []types.AttributeType {
+	var names []string
+	for k := range u.attributes {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	attrs := make([]types.AttributeType, 0, len(names))
+	for _, k := range names {
+		attrs = append(attrs, types.AttributeType{
+			Name:  aws.String(k),
+			Value: aws.String(u.attributes[k]),
+		})
+	}
+	return attrs
+}
+
+func (u *user) userType() *types.UserType {
+	return &types.UserType{
+		Username:             aws.String(u.username),
+		Attributes:           u.attributeTypes(),
+		Enabled:              u.enabled,
+		UserStatus:           u.status,
+		UserCreateDate:       aws.Time(u.created),
+		UserLastModifiedDate: aws.Time(u.modified),
+	}
+}
+
+var filterRe = regexp.MustCompile(`^\s*([\w:]+)\s*(\^?=)\s*"(.*)"\s*$`)
+
+// parseFilter parses a ListUsers filter expression such as `email ^= "user"`.
+func parseFilter(filter string) (func(*user) bool, error) {
+	if filter == "" {
+		return func(*user) bool { return true }, nil
+	}
+	m := filterRe.FindStringSubmatch(filter)
+	if m == nil {
+		return nil, &types.InvalidParameterException{Message: aws.String(fmt.Sprintf("Error while parsing filter: %s", filter))}
+	}
+	name, op, value := m[1], m[2], m[3]
+	return func(u *user) bool {
+		var v string
+		switch name {
+		case "username":
+			v = u.username
+		case "cognito:user_status":
+			v = string(u.status)
+		case "status":
+			v = "Disabled"
+			if u.enabled {
+				v = "Enabled"
+			}
+		default:
+			v = u.attributes[name]
+		}
+		if op == "^=" {
+			return strings.HasPrefix(v, value)
+		}
+		return v == value
+	}, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+func page(total int, maxResults *int32, nextToken *string) (int, int, *string, error) {
+	start := 0
+	if nextToken != nil {
+		n, err := strconv.Atoi(*nextToken)
+		if err != nil || n < 0 || n > total {
+			return 0, 0, nil, &types.InvalidParameterException{Message: aws.String("Invalid pagination token")}
+		}
+		start = n
+	}
+	limit := defaultMaxItems
+	if maxResults != nil && *maxResults > 0 {
+		limit = int(*maxResults)
+	}
+	end := min(start+limit, total)
+	var next *string
+	if end < total {
+		next = aws.String(strconv.Itoa(end))
+	}
+	return start, end, next, nil
+}
+
+func uuid() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+func randomString(n int) string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = chars[int(b[i])%len(chars)]
+	}
+	return string(b)
+}